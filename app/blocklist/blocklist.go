@@ -0,0 +1,350 @@
+// Package blocklist implements the resolver's domain blocklist: one or
+// more rule files loaded into a suffix-matching trie, with optional
+// fsnotify-based hot reload and a configurable block policy.
+package blocklist
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Policy selects how a blocked query is answered.
+type Policy string
+
+const (
+	// PolicyNXDOMAIN answers with NXDOMAIN, as if the name didn't exist.
+	PolicyNXDOMAIN Policy = "nxdomain"
+	// PolicyRefused answers with REFUSED.
+	PolicyRefused Policy = "refused"
+	// PolicyNull answers A/AAAA queries with 0.0.0.0/:: rather than an
+	// error code, the way many hosts-file-based blockers behave.
+	PolicyNull Policy = "null"
+	// PolicyCustomIP answers A/AAAA queries with the configured CustomIP,
+	// e.g. to redirect blocked domains to a sinkhole page.
+	PolicyCustomIP Policy = "custom-ip"
+)
+
+// ParsePolicy validates s as one of the Policy constants.
+func ParsePolicy(s string) (Policy, error) {
+	switch Policy(s) {
+	case PolicyNXDOMAIN, PolicyRefused, PolicyNull, PolicyCustomIP:
+		return Policy(s), nil
+	default:
+		return "", fmt.Errorf("blocklist: unknown policy %q", s)
+	}
+}
+
+// Options configures a List.
+type Options struct {
+	// Files are the rule files to load, in hosts, plain-domain-per-line,
+	// or AdBlock (||domain^, @@||domain^) format. Reloaded in full on
+	// every hot-reload trigger.
+	Files []string
+	// Policy selects how Match hits are meant to be answered. List
+	// itself never builds a reply; it's stored here purely so callers
+	// don't have to thread it through separately.
+	Policy Policy
+	// CustomIP is the address used when Policy is PolicyCustomIP.
+	CustomIP net.IP
+	// Watch enables fsnotify-based hot reload of Files.
+	Watch bool
+}
+
+// List is a loaded blocklist. It's safe for concurrent use, including
+// concurrent Match calls during a hot reload.
+type List struct {
+	files    []string
+	policy   Policy
+	customIP net.IP
+
+	mu      sync.RWMutex
+	blocked *trieNode
+	allowed *trieNode
+
+	blockedCount uint64
+
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+// Load reads opts.Files into a List and, if opts.Watch is set, starts
+// watching them for changes.
+func Load(opts Options) (*List, error) {
+	l := &List{
+		files:    opts.Files,
+		policy:   opts.Policy,
+		customIP: opts.CustomIP,
+	}
+
+	if err := l.reload(); err != nil {
+		return nil, err
+	}
+
+	if opts.Watch && len(opts.Files) > 0 {
+		if err := l.startWatch(); err != nil {
+			return nil, fmt.Errorf("blocklist: watch: %w", err)
+		}
+	}
+
+	return l, nil
+}
+
+// Policy reports the configured block policy.
+func (l *List) Policy() Policy { return l.policy }
+
+// CustomIP reports the address configured for PolicyCustomIP.
+func (l *List) CustomIP() net.IP { return l.customIP }
+
+// BlockedCount reports how many Match calls have returned a hit since
+// the List was created.
+func (l *List) BlockedCount() uint64 { return atomic.LoadUint64(&l.blockedCount) }
+
+// Close stops the hot-reload watcher, if one was started.
+func (l *List) Close() error {
+	if l.watcher == nil {
+		return nil
+	}
+	close(l.done)
+	return l.watcher.Close()
+}
+
+// Match reports whether name (or one of its parent domains) is blocked,
+// and the literal rule text that matched. An allowlist entry for name or
+// any ancestor takes precedence over a block at the same or a shallower
+// level.
+func (l *List) Match(name string) (rule string, blocked bool) {
+	labels := splitLabels(name)
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if _, ok := l.allowed.matchSuffix(labels); ok {
+		return "", false
+	}
+
+	rule, ok := l.blocked.matchSuffix(labels)
+	if !ok {
+		return "", false
+	}
+
+	atomic.AddUint64(&l.blockedCount, 1)
+	return rule, true
+}
+
+// reload re-parses every configured file from scratch and swaps in the
+// resulting tries. A file that fails to load is skipped with its error
+// noted, rather than aborting the whole reload.
+func (l *List) reload() error {
+	blocked := newTrieNode()
+	allowed := newTrieNode()
+
+	var errs []error
+	for _, path := range l.files {
+		if err := loadFile(path, blocked, allowed); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	l.mu.Lock()
+	l.blocked = blocked
+	l.allowed = allowed
+	l.mu.Unlock()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("blocklist: %v", errs)
+	}
+	return nil
+}
+
+// startWatch begins watching every configured file's parent directory
+// (fsnotify can't watch files directly through editor rename-swaps) and
+// triggers a full reload whenever one of them changes.
+func (l *List) startWatch() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	dirs := make(map[string]struct{})
+	for _, path := range l.files {
+		dirs[filepath.Dir(path)] = struct{}{}
+	}
+	for dir := range dirs {
+		if err := w.Add(dir); err != nil {
+			w.Close()
+			return fmt.Errorf("watch %s: %w", dir, err)
+		}
+	}
+
+	watched := make(map[string]struct{}, len(l.files))
+	for _, path := range l.files {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			abs = path
+		}
+		watched[abs] = struct{}{}
+	}
+
+	l.watcher = w
+	l.done = make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				abs, err := filepath.Abs(event.Name)
+				if err != nil {
+					abs = event.Name
+				}
+				if _, ours := watched[abs]; !ours {
+					continue
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					l.reload()
+				}
+			case _, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+			case <-l.done:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// loadFile parses path, which may mix hosts-format, plain-domain, and
+// AdBlock-style lines, inserting each rule into blocked or, for AdBlock
+// exception rules, allowed.
+func loadFile(path string, blocked, allowed *trieNode) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+
+		domains, exception := parseRule(line)
+		dest := blocked
+		if exception {
+			dest = allowed
+		}
+		for _, domain := range domains {
+			dest.insert(splitLabels(domain), line)
+		}
+	}
+	return scanner.Err()
+}
+
+// parseRule extracts the domain(s) a single rule line names, and reports
+// whether it's an AdBlock exception (allowlist) rule.
+func parseRule(line string) (domains []string, exception bool) {
+	switch {
+	case strings.HasPrefix(line, "@@||"):
+		return []string{trimAdBlock(strings.TrimPrefix(line, "@@"))}, true
+	case strings.HasPrefix(line, "||"):
+		return []string{trimAdBlock(line)}, false
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, false
+	}
+	if ip := net.ParseIP(fields[0]); ip != nil && len(fields) > 1 {
+		// hosts-format: "0.0.0.0 domain [alias...]"
+		return fields[1:], false
+	}
+	// plain domain-per-line
+	return []string{fields[0]}, false
+}
+
+// trimAdBlock strips the "||" prefix and any trailing "^" (or other
+// AdBlock rule options after it) from a domain-anchor rule.
+func trimAdBlock(s string) string {
+	s = strings.TrimPrefix(s, "||")
+	if i := strings.IndexByte(s, '^'); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// splitLabels normalizes name and splits it into DNS labels, e.g.
+// "Foo.Evil.com." -> ["foo", "evil", "com"].
+func splitLabels(name string) []string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if name == "" {
+		return nil
+	}
+	return strings.Split(name, ".")
+}
+
+// trieNode is one label's worth of a suffix-matching domain trie: rules
+// are inserted from the TLD down, so looking up a query name by walking
+// its labels right-to-left finds the broadest rule that covers it.
+type trieNode struct {
+	children map[string]*trieNode
+	terminal bool
+	rule     string
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{children: make(map[string]*trieNode)}
+}
+
+func (n *trieNode) insert(labels []string, rule string) {
+	cur := n
+	for i := len(labels) - 1; i >= 0; i-- {
+		child, ok := cur.children[labels[i]]
+		if !ok {
+			child = newTrieNode()
+			cur.children[labels[i]] = child
+		}
+		cur = child
+	}
+	cur.terminal = true
+	cur.rule = rule
+}
+
+// matchSuffix walks labels from the TLD inward, returning the rule text
+// of the deepest (most specific) terminal node reached. A query matches
+// if any ancestor domain, including itself, was inserted as a rule.
+func (n *trieNode) matchSuffix(labels []string) (string, bool) {
+	if n == nil {
+		return "", false
+	}
+
+	cur := n
+	var rule string
+	matched := false
+	for i := len(labels) - 1; i >= 0; i-- {
+		child, ok := cur.children[labels[i]]
+		if !ok {
+			break
+		}
+		cur = child
+		if cur.terminal {
+			rule = cur.rule
+			matched = true
+		}
+	}
+	return rule, matched
+}
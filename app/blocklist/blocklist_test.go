@@ -0,0 +1,88 @@
+package blocklist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeRules(t *testing.T, lines ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.txt")
+	content := ""
+	for _, l := range lines {
+		content += l + "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write rules: %v", err)
+	}
+	return path
+}
+
+func TestMatchSuffix(t *testing.T) {
+	path := writeRules(t, "evil.com", "0.0.0.0 ads.example", "||tracker.net^")
+
+	l, err := Load(Options{Files: []string{path}, Policy: PolicyNXDOMAIN})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		blocked bool
+	}{
+		{"evil.com", true},
+		{"foo.evil.com", true},
+		{"www.foo.evil.com", true},
+		{"ads.example", true},
+		{"sub.ads.example", true},
+		{"tracker.net", true},
+		{"cdn.tracker.net", true},
+		{"evilcom", false},
+		{"notevil.com", false},
+		{"example.org", false},
+	}
+	for _, c := range cases {
+		_, blocked := l.Match(c.name)
+		if blocked != c.blocked {
+			t.Errorf("Match(%q) blocked = %v, want %v", c.name, blocked, c.blocked)
+		}
+	}
+}
+
+func TestMatchAllowlistOverride(t *testing.T) {
+	path := writeRules(t, "evil.com", "@@||safe.evil.com^")
+
+	l, err := Load(Options{Files: []string{path}, Policy: PolicyNXDOMAIN})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if _, blocked := l.Match("bad.evil.com"); !blocked {
+		t.Errorf("Match(bad.evil.com) = unblocked, want blocked")
+	}
+	if _, blocked := l.Match("safe.evil.com"); blocked {
+		t.Errorf("Match(safe.evil.com) = blocked, want allowed")
+	}
+	if _, blocked := l.Match("deep.safe.evil.com"); blocked {
+		t.Errorf("Match(deep.safe.evil.com) = blocked, want allowed")
+	}
+}
+
+func TestBlockedCount(t *testing.T) {
+	path := writeRules(t, "evil.com")
+
+	l, err := Load(Options{Files: []string{path}, Policy: PolicyNXDOMAIN})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	l.Match("evil.com")
+	l.Match("example.org")
+	l.Match("foo.evil.com")
+
+	if got := l.BlockedCount(); got != 2 {
+		t.Errorf("BlockedCount() = %d, want 2", got)
+	}
+}
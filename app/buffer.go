@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// maxJumps bounds the number of compression-pointer hops ReadName will
+// follow before giving up, guarding against pointer cycles in malformed
+// or malicious packets.
+const maxJumps = 5
+
+// Buffer is a cursor over a raw DNS packet. It supports the random-access
+// reads that RFC 1035 name compression requires: a pointer can jump
+// backwards to any earlier offset in the packet, so callers need more than
+// a plain io.Reader.
+type Buffer struct {
+	data []byte
+	pos  int
+}
+
+// NewBuffer wraps data for reading. data is not copied; callers must not
+// mutate it while the Buffer is in use.
+func NewBuffer(data []byte) *Buffer {
+	return &Buffer{data: data}
+}
+
+// Pos returns the current read offset.
+func (b *Buffer) Pos() int {
+	return b.pos
+}
+
+// Seek moves the read cursor to an absolute offset.
+func (b *Buffer) Seek(pos int) error {
+	if pos < 0 || pos > len(b.data) {
+		return fmt.Errorf("buffer: seek %d out of range (len %d)", pos, len(b.data))
+	}
+	b.pos = pos
+	return nil
+}
+
+func (b *Buffer) ReadU8() (uint8, error) {
+	if b.pos+1 > len(b.data) {
+		return 0, fmt.Errorf("buffer: read u8 past end at %d", b.pos)
+	}
+	v := b.data[b.pos]
+	b.pos++
+	return v, nil
+}
+
+func (b *Buffer) ReadU16() (uint16, error) {
+	if b.pos+2 > len(b.data) {
+		return 0, fmt.Errorf("buffer: read u16 past end at %d", b.pos)
+	}
+	v := binary.BigEndian.Uint16(b.data[b.pos : b.pos+2])
+	b.pos += 2
+	return v, nil
+}
+
+func (b *Buffer) ReadU32() (uint32, error) {
+	if b.pos+4 > len(b.data) {
+		return 0, fmt.Errorf("buffer: read u32 past end at %d", b.pos)
+	}
+	v := binary.BigEndian.Uint32(b.data[b.pos : b.pos+4])
+	b.pos += 4
+	return v, nil
+}
+
+// ReadBytes returns the next n raw bytes and advances the cursor.
+func (b *Buffer) ReadBytes(n int) ([]byte, error) {
+	if n < 0 || b.pos+n > len(b.data) {
+		return nil, fmt.Errorf("buffer: read %d bytes past end at %d", n, b.pos)
+	}
+	v := b.data[b.pos : b.pos+n]
+	b.pos += n
+	return v, nil
+}
+
+// ReadName decodes a domain name starting at the current position,
+// following RFC 1035 section 4.1.4 compression pointers (the top two bits
+// of a length byte set to 11). Pointers may jump to any earlier offset in
+// the packet; jumpsAllowed caps how many pointer hops a single name may
+// take so a cyclic pointer chain can't spin forever.
+func (b *Buffer) ReadName() (string, error) {
+	var labels []string
+	pos := b.pos
+	jumped := false
+	jumps := 0
+
+	for {
+		if pos >= len(b.data) {
+			return "", fmt.Errorf("buffer: name length byte past end at %d", pos)
+		}
+		length := b.data[pos]
+
+		if length&0xC0 == 0xC0 {
+			if pos+2 > len(b.data) {
+				return "", fmt.Errorf("buffer: truncated compression pointer at %d", pos)
+			}
+			if jumps >= maxJumps {
+				return "", fmt.Errorf("buffer: name exceeds %d compression jumps", maxJumps)
+			}
+			offset := int(binary.BigEndian.Uint16(b.data[pos:pos+2]) & 0x3FFF)
+			if !jumped {
+				b.pos = pos + 2
+				jumped = true
+			}
+			pos = offset
+			jumps++
+			continue
+		}
+
+		if length&0xC0 != 0 {
+			return "", fmt.Errorf("buffer: reserved label length bits at %d", pos)
+		}
+
+		pos++
+		if length == 0 {
+			break
+		}
+		if pos+int(length) > len(b.data) {
+			return "", fmt.Errorf("buffer: label past end at %d", pos)
+		}
+		labels = append(labels, string(b.data[pos:pos+int(length)]))
+		pos += int(length)
+	}
+
+	if !jumped {
+		b.pos = pos
+	}
+
+	return strings.Join(labels, "."), nil
+}
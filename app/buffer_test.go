@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestBufferReadWriteName(t *testing.T) {
+	w := NewWriter()
+	if err := w.WriteName("example.com"); err != nil {
+		t.Fatalf("WriteName: %v", err)
+	}
+
+	b := NewBuffer(w.Bytes())
+	name, err := b.ReadName()
+	if err != nil {
+		t.Fatalf("ReadName: %v", err)
+	}
+	if name != "example.com" {
+		t.Errorf("ReadName = %q, want %q", name, "example.com")
+	}
+	if b.Pos() != w.Len() {
+		t.Errorf("Pos = %d, want %d (end of the written name)", b.Pos(), w.Len())
+	}
+}
+
+func TestBufferReadNameFollowsCompressionPointer(t *testing.T) {
+	w := NewWriter()
+	if err := w.WriteName("www.example.com"); err != nil {
+		t.Fatalf("WriteName (first): %v", err)
+	}
+	// example.com is already written as a suffix of www.example.com, so
+	// this second name should compress down to a two-byte pointer.
+	before := w.Len()
+	if err := w.WriteName("example.com"); err != nil {
+		t.Fatalf("WriteName (second): %v", err)
+	}
+	if got := w.Len() - before; got != 2 {
+		t.Fatalf("second WriteName wrote %d bytes, want 2 (a compression pointer)", got)
+	}
+
+	b := NewBuffer(w.Bytes())
+	if _, err := b.ReadName(); err != nil {
+		t.Fatalf("ReadName (first): %v", err)
+	}
+	name, err := b.ReadName()
+	if err != nil {
+		t.Fatalf("ReadName (second): %v", err)
+	}
+	if name != "example.com" {
+		t.Errorf("ReadName (second) = %q, want %q", name, "example.com")
+	}
+}
+
+func TestBufferReadNameRejectsPointerCycle(t *testing.T) {
+	// A pointer at offset 0 that points right back to offset 0.
+	data := []byte{0xC0, 0x00}
+	b := NewBuffer(data)
+	if _, err := b.ReadName(); err == nil {
+		t.Fatal("ReadName succeeded on a self-referencing pointer, want an error")
+	}
+}
+
+func TestBufferReadPastEndErrors(t *testing.T) {
+	b := NewBuffer([]byte{0x01})
+	if _, err := b.ReadU16(); err == nil {
+		t.Fatal("ReadU16 succeeded past the end of the buffer, want an error")
+	}
+}
@@ -0,0 +1,269 @@
+// Package cache implements the resolver's response cache: an in-memory
+// LRU in front of an on-disk BoltDB store, keyed by (qname, qtype,
+// qclass), with per-RR TTL decay and RFC 2308 negative caching.
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/gob"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var bucketName = []byte("dnscache")
+
+// Key identifies a cached answer set. Name must already be lowercased by
+// the caller so lookups are case-insensitive per RFC 1035 section 4.1.4.
+type Key struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+func (k Key) String() string {
+	return fmt.Sprintf("%s|%d|%d", k.Name, k.Type, k.Class)
+}
+
+// RR is a cacheable resource record. RData holds the already-packed wire
+// format of the record's RDATA, letting this package stay independent of
+// the codec's typed RData representation.
+type RR struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+	RData []byte
+}
+
+// Entry is either a positive answer set (RRs) or, per RFC 2308, a
+// negative cache entry for NXDOMAIN/NODATA responses. Rescode carries the
+// original response code (the codec's ResultCode, which this package
+// can't import) so a negative hit can be replayed as the NXDOMAIN or
+// NODATA (NOERROR with no answers) it actually was, rather than
+// collapsing both into NXDOMAIN.
+type Entry struct {
+	RRs         []RR
+	Negative    bool
+	Rescode     uint16
+	NegativeTTL uint32
+	InsertedAt  time.Time
+}
+
+// Cache is safe for concurrent use.
+type Cache struct {
+	mu       sync.Mutex
+	lru      *list.List
+	items    map[string]*list.Element
+	maxItems int
+	db       *bolt.DB
+	minTTL   time.Duration
+	maxTTL   time.Duration
+	skip     *regexp.Regexp
+}
+
+type lruEntry struct {
+	key   string
+	entry Entry
+}
+
+// Options configures a Cache. Dir may be empty to run memory-only, which
+// is useful for tests and for --cache-size=0 deployments.
+type Options struct {
+	Dir            string
+	MaxItems       int
+	MinTTL         time.Duration
+	MaxTTL         time.Duration
+	NoCachePattern string
+}
+
+// Open builds a Cache from opts, opening the on-disk store at opts.Dir if
+// one is given.
+func Open(opts Options) (*Cache, error) {
+	c := &Cache{
+		lru:      list.New(),
+		items:    make(map[string]*list.Element),
+		maxItems: opts.MaxItems,
+		minTTL:   opts.MinTTL,
+		maxTTL:   opts.MaxTTL,
+	}
+
+	if opts.NoCachePattern != "" {
+		re, err := regexp.Compile(opts.NoCachePattern)
+		if err != nil {
+			return nil, fmt.Errorf("cache: compile --no-cache-pattern: %w", err)
+		}
+		c.skip = re
+	}
+
+	if opts.Dir != "" {
+		db, err := bolt.Open(opts.Dir+"/cache.db", 0600, &bolt.Options{Timeout: time.Second})
+		if err != nil {
+			return nil, fmt.Errorf("cache: open %s: %w", opts.Dir, err)
+		}
+		if err := db.Update(func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists(bucketName)
+			return err
+		}); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("cache: create bucket: %w", err)
+		}
+		c.db = db
+	}
+
+	return c, nil
+}
+
+func (c *Cache) Close() error {
+	if c.db == nil {
+		return nil
+	}
+	return c.db.Close()
+}
+
+// Skip reports whether name matches the configured --no-cache-pattern and
+// should bypass caching entirely.
+func (c *Cache) Skip(name string) bool {
+	return c.skip != nil && c.skip.MatchString(name)
+}
+
+// Get returns a copy of the cached entry for key with every RR's TTL
+// (and, for negative entries, the negative TTL) decremented by the time
+// elapsed since insertion. It reports a miss once that decay would bring
+// an entry's effective TTL to zero.
+func (c *Cache) Get(key Key) (Entry, bool) {
+	k := key.String()
+
+	c.mu.Lock()
+	elem, ok := c.items[k]
+	c.mu.Unlock()
+
+	var entry Entry
+	if ok {
+		entry = elem.Value.(*lruEntry).entry
+	} else if c.db != nil {
+		stored, found, err := c.loadFromDisk(k)
+		if err != nil || !found {
+			return Entry{}, false
+		}
+		entry = stored
+		c.touch(k, entry)
+	} else {
+		return Entry{}, false
+	}
+
+	elapsed := uint32(time.Since(entry.InsertedAt).Seconds())
+
+	if entry.Negative {
+		if elapsed >= entry.NegativeTTL {
+			return Entry{}, false
+		}
+		entry.NegativeTTL -= elapsed
+		return entry, true
+	}
+
+	live := make([]RR, 0, len(entry.RRs))
+	for _, rr := range entry.RRs {
+		if elapsed >= rr.TTL {
+			continue
+		}
+		rr.TTL -= elapsed
+		live = append(live, rr)
+	}
+	if len(live) == 0 {
+		return Entry{}, false
+	}
+	entry.RRs = live
+	return entry, true
+}
+
+// Put stores entry under key, clamping positive RR TTLs (and the
+// negative TTL) to [minTTL, maxTTL] when those are configured.
+func (c *Cache) Put(key Key, entry Entry) error {
+	entry.InsertedAt = time.Now()
+
+	if entry.Negative {
+		entry.NegativeTTL = c.clamp(entry.NegativeTTL)
+	} else {
+		for i := range entry.RRs {
+			entry.RRs[i].TTL = c.clamp(entry.RRs[i].TTL)
+		}
+	}
+
+	k := key.String()
+	c.touch(k, entry)
+
+	if c.db != nil {
+		return c.storeToDisk(k, entry)
+	}
+	return nil
+}
+
+func (c *Cache) clamp(ttl uint32) uint32 {
+	if c.minTTL > 0 && time.Duration(ttl)*time.Second < c.minTTL {
+		ttl = uint32(c.minTTL.Seconds())
+	}
+	if c.maxTTL > 0 && time.Duration(ttl)*time.Second > c.maxTTL {
+		ttl = uint32(c.maxTTL.Seconds())
+	}
+	return ttl
+}
+
+// touch inserts or refreshes k as the most-recently-used item, evicting
+// the oldest entry if the cache is at capacity.
+func (c *Cache) touch(k string, entry Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[k]; ok {
+		elem.Value.(*lruEntry).entry = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	elem := c.lru.PushFront(&lruEntry{key: k, entry: entry})
+	c.items[k] = elem
+
+	if c.maxItems > 0 && c.lru.Len() > c.maxItems {
+		oldest := c.lru.Back()
+		if oldest != nil {
+			c.lru.Remove(oldest)
+			delete(c.items, oldest.Value.(*lruEntry).key)
+		}
+	}
+}
+
+func (c *Cache) loadFromDisk(k string) (Entry, bool, error) {
+	var entry Entry
+	found := false
+	err := c.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketName).Get([]byte(k))
+		if v == nil {
+			return nil
+		}
+		found = true
+		return gob.NewDecoder(bytes.NewReader(v)).Decode(&entry)
+	})
+	return entry, found, err
+}
+
+func (c *Cache) storeToDisk(k string, entry Entry) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(entry); err != nil {
+		return fmt.Errorf("cache: encode entry: %w", err)
+	}
+	return c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Put([]byte(k), buf.Bytes())
+	})
+}
+
+// NormalizeName canonicalizes a question name for use in a Key: lowercased
+// with any trailing root dot removed.
+func NormalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
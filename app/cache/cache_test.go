@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCachePutGetRoundTrip(t *testing.T) {
+	c, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer c.Close()
+
+	key := Key{Name: "example.com", Type: 1, Class: 1}
+	entry := Entry{RRs: []RR{{Name: "example.com", Type: 1, Class: 1, TTL: 300, RData: []byte{1, 2, 3, 4}}}}
+	if err := c.Put(key, entry); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	got, ok := c.Get(key)
+	if !ok {
+		t.Fatal("Get reported a miss right after Put")
+	}
+	if len(got.RRs) != 1 || got.RRs[0].TTL != 300 {
+		t.Errorf("RRs = %+v, want one RR with TTL 300", got.RRs)
+	}
+}
+
+func TestCacheGetDecaysTTL(t *testing.T) {
+	c, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer c.Close()
+
+	key := Key{Name: "example.com", Type: 1, Class: 1}
+	entry := Entry{RRs: []RR{{Name: "example.com", Type: 1, Class: 1, TTL: 300}}}
+	if err := c.Put(key, entry); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	// Backdate the insertion instead of sleeping in the test.
+	c.items[key.String()].Value.(*lruEntry).entry.InsertedAt = time.Now().Add(-10 * time.Second)
+
+	got, ok := c.Get(key)
+	if !ok {
+		t.Fatal("Get reported a miss for an entry that should still be live")
+	}
+	if got.RRs[0].TTL != 290 {
+		t.Errorf("TTL = %d, want 290 (300 - 10s elapsed)", got.RRs[0].TTL)
+	}
+}
+
+func TestCacheGetExpiresWhenTTLElapses(t *testing.T) {
+	c, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer c.Close()
+
+	key := Key{Name: "example.com", Type: 1, Class: 1}
+	entry := Entry{RRs: []RR{{Name: "example.com", Type: 1, Class: 1, TTL: 5}}}
+	if err := c.Put(key, entry); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	c.items[key.String()].Value.(*lruEntry).entry.InsertedAt = time.Now().Add(-10 * time.Second)
+
+	if _, ok := c.Get(key); ok {
+		t.Fatal("Get hit on an entry whose TTL has fully elapsed")
+	}
+}
+
+func TestCacheNegativeEntryExpiresIndependently(t *testing.T) {
+	c, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer c.Close()
+
+	key := Key{Name: "nxdomain.example.com", Type: 1, Class: 1}
+	entry := Entry{Negative: true, Rescode: 3, NegativeTTL: 60}
+	if err := c.Put(key, entry); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	got, ok := c.Get(key)
+	if !ok {
+		t.Fatal("Get reported a miss right after Put of a negative entry")
+	}
+	if !got.Negative || got.Rescode != 3 {
+		t.Errorf("entry = %+v, want a negative entry with Rescode 3", got)
+	}
+
+	c.items[key.String()].Value.(*lruEntry).entry.InsertedAt = time.Now().Add(-70 * time.Second)
+	if _, ok := c.Get(key); ok {
+		t.Fatal("Get hit on a negative entry whose TTL has fully elapsed")
+	}
+}
+
+func TestCachePutClampsTTLToConfiguredRange(t *testing.T) {
+	c, err := Open(Options{MinTTL: 30 * time.Second, MaxTTL: 120 * time.Second})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer c.Close()
+
+	below := Key{Name: "below.example.com", Type: 1, Class: 1}
+	if err := c.Put(below, Entry{RRs: []RR{{TTL: 5}}}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got, _ := c.Get(below); got.RRs[0].TTL != 30 {
+		t.Errorf("TTL = %d, want clamped up to minTTL 30", got.RRs[0].TTL)
+	}
+
+	above := Key{Name: "above.example.com", Type: 1, Class: 1}
+	if err := c.Put(above, Entry{RRs: []RR{{TTL: 99999}}}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got, _ := c.Get(above); got.RRs[0].TTL != 120 {
+		t.Errorf("TTL = %d, want clamped down to maxTTL 120", got.RRs[0].TTL)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsedAtCapacity(t *testing.T) {
+	c, err := Open(Options{MaxItems: 2})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer c.Close()
+
+	a := Key{Name: "a.example.com", Type: 1, Class: 1}
+	b := Key{Name: "b.example.com", Type: 1, Class: 1}
+	cc := Key{Name: "c.example.com", Type: 1, Class: 1}
+
+	c.Put(a, Entry{RRs: []RR{{TTL: 300}}})
+	c.Put(b, Entry{RRs: []RR{{TTL: 300}}})
+	// Put a again so it's more recently used than b. Get alone doesn't
+	// move an in-memory hit to the front of the LRU; only Put does.
+	c.Put(a, Entry{RRs: []RR{{TTL: 300}}})
+	c.Put(cc, Entry{RRs: []RR{{TTL: 300}}})
+
+	if _, ok := c.Get(b); ok {
+		t.Error("Get(b) hit, want b evicted as the least recently used entry")
+	}
+	if _, ok := c.Get(a); !ok {
+		t.Error("Get(a) missed, want a retained since it was touched before the eviction")
+	}
+	if _, ok := c.Get(cc); !ok {
+		t.Error("Get(c) missed, want c retained since it was just inserted")
+	}
+}
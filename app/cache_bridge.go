@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ndrpp/codecrafters-dns-server-go/cache"
+)
+
+// toCacheRR packs a Record's RDATA to raw wire bytes so the cache package
+// can store it without depending on this package's typed RData.
+func toCacheRR(r Record) (cache.RR, error) {
+	w := NewWriter()
+	if err := r.Data.Pack(w); err != nil {
+		return cache.RR{}, fmt.Errorf("cache bridge: pack %s: %w", r.Name, err)
+	}
+	return cache.RR{
+		Name:  r.Name,
+		Type:  r.Type,
+		Class: r.Class,
+		TTL:   r.TTL,
+		RData: w.Bytes(),
+	}, nil
+}
+
+// fromCacheRR reverses toCacheRR, re-parsing the typed RData from the
+// stored wire bytes.
+func fromCacheRR(rr cache.RR) (Record, error) {
+	buf := NewBuffer(rr.RData)
+	data, err := unpackRData(rr.Type, uint16(len(rr.RData)), buf)
+	if err != nil {
+		return Record{}, fmt.Errorf("cache bridge: unpack %s: %w", rr.Name, err)
+	}
+	return Record{
+		Name:  rr.Name,
+		Type:  rr.Type,
+		Class: rr.Class,
+		TTL:   rr.TTL,
+		Data:  data,
+	}, nil
+}
+
+// soaMinimum returns the MINIMUM field of the first SOA record in rrs, for
+// use as the RFC 2308 negative-caching TTL.
+func soaMinimum(rrs []Record) (uint32, bool) {
+	for _, rr := range rrs {
+		if soa, ok := rr.Data.(SOARecord); ok {
+			return soa.Minimum, true
+		}
+	}
+	return 0, false
+}
+
+// cacheKey builds the cache lookup key for a question, normalizing the
+// name the same way on every call so hits are case-insensitive.
+func cacheKey(q Question) cache.Key {
+	return cache.Key{Name: cache.NormalizeName(q.Name), Type: q.Type, Class: q.Class}
+}
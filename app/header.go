@@ -0,0 +1,139 @@
+package main
+
+type ResultCode int
+
+const (
+	NOERROR ResultCode = iota
+	FORMERR
+	SERVFAIL
+	NXDOMAIN
+	NOTIMP
+	REFUSED
+)
+
+type DNSHeader struct {
+	Id uint16
+
+	Recursion_desired    bool  // 1 bit
+	Truncated_message    bool  // 1 bit
+	Authoritative_answer bool  // 1 bit
+	Opcode               uint8 // 4 bits
+	Response             bool  // 1 bit
+
+	Rescode             ResultCode // 4 bits
+	Checking_disabled   bool       // 1 bit
+	Authed_data         bool       // 1 bit
+	Z                   bool       // 1 bit
+	Recursion_available bool       // 1 bit
+
+	Questions             uint16 // 16 bits
+	Answers               uint16 // 16 bits
+	Authoritative_entries uint16 // 16 bits
+	Resource_entries      uint16 // 16 bits
+}
+
+func NewDNSHeader() DNSHeader {
+	return DNSHeader{
+		Id: 0,
+
+		Response:             false,
+		Opcode:               0,
+		Authoritative_answer: false,
+		Truncated_message:    false,
+		Recursion_desired:    false,
+		Recursion_available:  false,
+		Z:                    false,
+		Rescode:              NOERROR,
+
+		Questions:             0,
+		Answers:               0,
+		Authoritative_entries: 0,
+		Resource_entries:      0,
+
+		Checking_disabled: false,
+		Authed_data:       false,
+	}
+}
+
+// parseHeader decodes the fixed 12-byte DNS header from buf.
+func parseHeader(buf *Buffer) (DNSHeader, error) {
+	header := NewDNSHeader()
+
+	id, err := buf.ReadU16()
+	if err != nil {
+		return header, err
+	}
+	header.Id = id
+
+	flags, err := buf.ReadU16()
+	if err != nil {
+		return header, err
+	}
+	header.Response = flags&0x8000 != 0
+	header.Opcode = uint8((flags & 0x7800) >> 11)
+	header.Authoritative_answer = flags&0x0400 != 0
+	header.Truncated_message = flags&0x0200 != 0
+	header.Recursion_desired = flags&0x0100 != 0
+	header.Recursion_available = flags&0x0080 != 0
+	header.Z = flags&0x0040 != 0
+	header.Checking_disabled = flags&0x0010 != 0
+	header.Authed_data = flags&0x0020 != 0
+	header.Rescode = ResultCode(flags & 0x000F)
+
+	if header.Questions, err = buf.ReadU16(); err != nil {
+		return header, err
+	}
+	if header.Answers, err = buf.ReadU16(); err != nil {
+		return header, err
+	}
+	if header.Authoritative_entries, err = buf.ReadU16(); err != nil {
+		return header, err
+	}
+	if header.Resource_entries, err = buf.ReadU16(); err != nil {
+		return header, err
+	}
+
+	return header, nil
+}
+
+// packHeader writes the fixed 12-byte DNS header to w.
+func packHeader(w *Writer, header DNSHeader) error {
+	w.WriteU16(header.Id)
+	w.WriteU16(buildFlags(header))
+	w.WriteU16(header.Questions)
+	w.WriteU16(header.Answers)
+	w.WriteU16(header.Authoritative_entries)
+	w.WriteU16(header.Resource_entries)
+	return nil
+}
+
+func buildFlags(header DNSHeader) uint16 {
+	var flags uint16
+	if header.Response {
+		flags |= 0x8000
+	}
+	flags |= uint16(header.Opcode) << 11
+	if header.Authoritative_answer {
+		flags |= 0x0400
+	}
+	if header.Truncated_message {
+		flags |= 0x0200
+	}
+	if header.Recursion_desired {
+		flags |= 0x0100
+	}
+	if header.Recursion_available {
+		flags |= 0x0080
+	}
+	if header.Z {
+		flags |= 0x0040
+	}
+	if header.Checking_disabled {
+		flags |= 0x0010
+	}
+	if header.Authed_data {
+		flags |= 0x0020
+	}
+	flags |= uint16(header.Rescode) & 0x000F
+	return flags
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/ndrpp/codecrafters-dns-server-go/cache"
+)
+
+// localZoneTTL is the TTL attached to synthesized local-zone answers.
+// These entries are static for the lifetime of the process, so there's
+// no hot-reload freshness tradeoff to weigh the way there is for
+// blocklistTTL; a generous TTL just saves the client re-asking.
+const localZoneTTL = 3600
+
+// LocalZones holds a small, static name -> records table that Resolve
+// consults ahead of the cache and upstream/iterative resolution, so this
+// server can authoritatively answer a handful of names itself (e.g.
+// internal hosts) without involving an upstream at all. It's read-only
+// after construction, so it's safe for concurrent use without locking.
+type LocalZones struct {
+	zones map[string][]Record
+}
+
+// NewLocalZones builds a LocalZones table from --local-zone entries of
+// the form NAME=IP. The address family picks the record type: an IPv4
+// address becomes an A record, anything else an AAAA record.
+func NewLocalZones(entries []string) (*LocalZones, error) {
+	zones := make(map[string][]Record, len(entries))
+	for _, entry := range entries {
+		name, addr, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("local zone: --local-zone %q must be of the form NAME=IP", entry)
+		}
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return nil, fmt.Errorf("local zone: --local-zone %q: invalid IP %q", entry, addr)
+		}
+
+		var rr Record
+		if v4 := ip.To4(); v4 != nil {
+			rr = Record{Name: name, Type: TypeA, Class: ClassIN, TTL: localZoneTTL, Data: ARecord{IP: v4}}
+		} else {
+			rr = Record{Name: name, Type: TypeAAAA, Class: ClassIN, TTL: localZoneTTL, Data: AAAARecord{IP: ip}}
+		}
+
+		key := cache.NormalizeName(name)
+		zones[key] = append(zones[key], rr)
+	}
+	return &LocalZones{zones: zones}, nil
+}
+
+// Lookup reports whether name is configured as a local zone at all, and
+// if so returns its records. A name can be configured with no record of
+// the type the caller ultimately wants (e.g. an AAAA query against a
+// zone that only has an A record); the caller is expected to filter by
+// type and treat an empty result as authoritative NODATA rather than
+// falling through to the cache or upstream.
+func (z *LocalZones) Lookup(name string) ([]Record, bool) {
+	rrs, ok := z.zones[cache.NormalizeName(name)]
+	return rrs, ok
+}
@@ -1,230 +1,167 @@
 package main
 
 import (
-	"bytes"
-	"encoding/binary"
+	"context"
+	"flag"
 	"fmt"
-	"io"
 	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/ndrpp/codecrafters-dns-server-go/blocklist"
+	"github.com/ndrpp/codecrafters-dns-server-go/cache"
+	"github.com/ndrpp/codecrafters-dns-server-go/nbns"
 )
 
-type Question struct {
-	Name  string
-	Type  uint16
-	Class uint16
-}
+// stringSliceFlag collects every occurrence of a repeatable flag, e.g.
+// --upstream 1.1.1.1 --upstream 8.8.8.8.
+type stringSliceFlag []string
 
-type Record struct {
-	Name  string
-	Type  uint16
-	Class uint16
-	TTL   uint32
-	Len   uint16
-	Data  string
-}
-
-type ResultCode int
-
-const (
-	NOERROR ResultCode = iota
-	FORMERR
-	SERVFAIL
-	NXDOMAIN
-	NOTIMP
-	REFUSED
-)
-
-type DNSHeader struct {
-	Id uint16
-
-	Recursion_desired    bool  // 1 bit
-	Truncated_message    bool  // 1 bit
-	Authoritative_answer bool  // 1 bit
-	Opcode               uint8 // 4 bits
-	Response             bool  // 1 bit
-
-	Rescode             ResultCode // 4 bits
-	Checking_disabled   bool       // 1 bit
-	Authed_data         bool       // 1 bit
-	Z                   bool       // 1 bit
-	Recursion_available bool       // 1 bit
-
-	Questions             uint16 // 16 bits
-	Answers               uint16 // 16 bits
-	Authoritative_entries uint16 // 16 bits
-	Resource_entries      uint16 // 16 bits
-}
-
-func NewDNSHeader() DNSHeader {
-	return DNSHeader{
-		Id: 0,
-
-		Response:             false,
-		Opcode:               0,
-		Authoritative_answer: false,
-		Truncated_message:    false,
-		Recursion_desired:    false,
-		Recursion_available:  false,
-		Z:                    false,
-		Rescode:              NOERROR,
-
-		Questions:             0,
-		Answers:               0,
-		Authoritative_entries: 0,
-		Resource_entries:      0,
-
-		Checking_disabled: false,
-		Authed_data:       false,
-	}
-}
-
-type DNSMessage struct {
-	Header     DNSHeader
-	Question   []Question
-	Answer     []Record
-	Authority  []Record
-	Additional []Record
+func (s *stringSliceFlag) String() string { return strings.Join(*s, ",") }
+func (s *stringSliceFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
 }
 
 func main() {
-	udpAddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:2053")
+	var upstreams stringSliceFlag
+	flag.Var(&upstreams, "upstream", "upstream DNS server to forward to (repeatable); ignored in recursive mode")
+	mode := flag.String("resolver-mode", "forward", "resolver mode: forward|recursive")
+	cacheDir := flag.String("cache-dir", "", "directory for the on-disk response cache (empty disables persistence, memory-only)")
+	cacheSize := flag.Int("cache-size", 10000, "maximum number of cache entries held in memory")
+	cacheMinTTL := flag.Duration("cache-min-ttl", 0, "floor applied to every cached TTL")
+	cacheMaxTTL := flag.Duration("cache-max-ttl", 0, "ceiling applied to every cached TTL (0 disables)")
+	noCachePattern := flag.String("no-cache-pattern", "", "regexp of query names to always resolve fresh, bypassing the cache")
+	var blocklistFiles stringSliceFlag
+	flag.Var(&blocklistFiles, "blocklist-file", "blocklist rule file (hosts, plain-domain, or AdBlock format; repeatable)")
+	blocklistPolicy := flag.String("blocklist-policy", "nxdomain", "how to answer blocked queries: nxdomain|refused|null|custom-ip")
+	blocklistCustomIP := flag.String("blocklist-custom-ip", "", "address to return for blocked A/AAAA queries under --blocklist-policy=custom-ip")
+	enableNBNS := flag.Bool("enable-nbns", false, "also answer NBNS (NetBIOS Name Service) name-query broadcasts on UDP/137")
+	var nbnsNames stringSliceFlag
+	flag.Var(&nbnsNames, "nbns-name", "NBNS local name mapping as NAME=IP (repeatable); IP is this responder's answer for NAME")
+	var localZones stringSliceFlag
+	flag.Var(&localZones, "local-zone", "local zone mapping as NAME=IP (repeatable); answered authoritatively ahead of the cache and upstream")
+	flag.Parse()
+
+	respCache, err := cache.Open(cache.Options{
+		Dir:            *cacheDir,
+		MaxItems:       *cacheSize,
+		MinTTL:         *cacheMinTTL,
+		MaxTTL:         *cacheMaxTTL,
+		NoCachePattern: *noCachePattern,
+	})
 	if err != nil {
-		fmt.Println("Failed to resolve UDP address:", err)
+		fmt.Println("Failed to open cache:", err)
 		return
 	}
+	defer respCache.Close()
 
-	udpConn, err := net.ListenUDP("udp", udpAddr)
+	bl, err := buildBlocklist(blocklistFiles, *blocklistPolicy, *blocklistCustomIP)
 	if err != nil {
-		fmt.Println("Failed to bind to address:", err)
+		fmt.Println("Failed to configure blocklist:", err)
 		return
 	}
-	defer udpConn.Close()
-
-	buf := make([]byte, 512)
-
-	for {
-		size, source, err := udpConn.ReadFromUDP(buf)
-		if err != nil {
-			fmt.Println("Error receiving data:", err)
-			break
-		}
+	if bl != nil {
+		defer bl.Close()
+	}
 
-		receivedData := string(buf[:size])
-		fmt.Printf("Received %d bytes from %s: %s\n", size, source, receivedData)
+	lz, err := NewLocalZones(localZones)
+	if err != nil {
+		fmt.Println("Failed to configure local zones:", err)
+		return
+	}
 
-		response := HandleReceivedData(receivedData)
+	resolver, err := buildResolver(*mode, upstreams, respCache, bl, lz)
+	if err != nil {
+		fmt.Println("Failed to configure resolver:", err)
+		return
+	}
 
-		_, err = udpConn.WriteToUDP(response, source)
+	var nbnsResponder *nbns.Responder
+	if *enableNBNS {
+		nbnsResponder, err = buildNBNS(nbnsNames)
 		if err != nil {
-			fmt.Println("Failed to send response:", err)
+			fmt.Println("Failed to configure NBNS:", err)
+			return
 		}
 	}
-}
 
-func HandleReceivedData(data string) []byte {
-	header := parseHeader(data)
-	buf := BuildHeader(header)
-
-	question := Question{
-		Name:  "\x0ccodecrafters\x02io\x00",
-		Type:  1,
-		Class: 1,
-	}
-	buf = append(buf, BuildQuestion(question)...)
-
-	answer := Record{
-		Name:  "\x0ccodecrafters\x02io\x00",
-		Type:  1,
-		Class: 1,
-		TTL:   60,
-		Len:   4,
-		Data:  "\x08\x08\x08\x08",
+	server, err := NewServer("127.0.0.1:2053", resolver, "0.0.0.0:137", nbnsResponder)
+	if err != nil {
+		fmt.Println("Failed to start server:", err)
+		return
 	}
-	buf = append(buf, BuildAnswer(answer)...)
-
-	return buf
-}
-
-func parseHeader(data string) DNSHeader {
-	bd := []byte(data)
 
-	header := NewDNSHeader()
-	header.Id = binary.BigEndian.Uint16(bd[0:2])
-	header.Questions = binary.BigEndian.Uint16(bd[4:6])
-	header.Answers = binary.BigEndian.Uint16(bd[4:6])
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	flags := binary.BigEndian.Uint16(bd[2:4])
-	header.Response = true
-	header.Authoritative_answer = false
-	header.Truncated_message = false
-	header.Opcode = uint8((flags & 0x7800) >> 11)
-	header.Recursion_desired = (flags & 0x0100) != 0
-
-	header.Recursion_available = false
-	header.Z = false
-	header.Rescode = NOTIMP
-
-	return header
+	server.Serve(ctx)
 }
 
-func BuildAnswer(r Record) []byte {
-	var b bytes.Buffer
-	w := io.Writer(&b)
+func buildResolver(mode string, upstreams stringSliceFlag, c *cache.Cache, bl *blocklist.List, lz *LocalZones) (*Resolver, error) {
+	resolverMode := ResolverMode(mode)
 
-	w.Write([]byte(r.Name))
-	binary.Write(w, binary.BigEndian, r.Type)
-	binary.Write(w, binary.BigEndian, r.Class)
-	binary.Write(w, binary.BigEndian, r.TTL)
-	binary.Write(w, binary.BigEndian, r.Len)
-	w.Write([]byte(r.Data))
+	if resolverMode == ModeRecursive {
+		return NewResolver(ModeRecursive, nil, c, bl, lz), nil
+	}
 
-	return b.Bytes()
+	if len(upstreams) == 0 {
+		upstreams = stringSliceFlag{"8.8.8.8:53"}
+	}
+	pool, err := parseUpstreamList(upstreams, RoundRobin)
+	if err != nil {
+		return nil, fmt.Errorf("resolver: %w", err)
+	}
+	return NewResolver(ModeForward, pool, c, bl, lz), nil
 }
 
-func BuildQuestion(question Question) []byte {
-	var b bytes.Buffer
-	w := io.Writer(&b)
-
-	w.Write([]byte(question.Name))
-	binary.Write(w, binary.BigEndian, question.Type)
-	binary.Write(w, binary.BigEndian, question.Class)
+// buildBlocklist builds the blocklist from the given files and policy,
+// or returns a nil List (which disables blocking entirely) when no
+// files are configured.
+func buildBlocklist(files stringSliceFlag, policy, customIP string) (*blocklist.List, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
 
-	return b.Bytes()
-}
+	p, err := blocklist.ParsePolicy(policy)
+	if err != nil {
+		return nil, fmt.Errorf("blocklist: %w", err)
+	}
 
-func BuildHeader(header DNSHeader) []byte {
-	buf := make([]byte, 12)
-	binary.BigEndian.PutUint16(buf[0:2], header.Id)
-	binary.BigEndian.PutUint16(buf[2:4], BuildFlags(header))
-	binary.BigEndian.PutUint16(buf[4:6], header.Questions)
-	binary.BigEndian.PutUint16(buf[6:8], header.Answers)
+	var ip net.IP
+	if customIP != "" {
+		ip = net.ParseIP(customIP)
+		if ip == nil {
+			return nil, fmt.Errorf("blocklist: invalid --blocklist-custom-ip %q", customIP)
+		}
+	}
 
-	return buf
+	return blocklist.Load(blocklist.Options{
+		Files:    files,
+		Policy:   p,
+		CustomIP: ip,
+		Watch:    true,
+	})
 }
 
-func BuildFlags(header DNSHeader) uint16 {
-	var flags uint16
-	if header.Response {
-		flags |= 0x8000
-	}
-	flags |= uint16(header.Opcode) << 11
-	if header.Authoritative_answer {
-		flags |= 0x0400
-	}
-	if header.Truncated_message {
-		flags |= 0x0200
-	}
-	if header.Recursion_desired {
-		flags |= 0x0100
-	}
-	if header.Recursion_available {
-		flags |= 0x0080
-	}
-	if header.Z == true {
-		flags |= uint16(1) << 4
-	} else {
-		flags |= uint16(0) << 4
+// buildNBNS parses --nbns-name=NAME=IP entries into an nbns.Responder.
+func buildNBNS(entries stringSliceFlag) (*nbns.Responder, error) {
+	names := make(map[string]nbns.Entry, len(entries))
+	for _, entry := range entries {
+		name, addr, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("nbns: --nbns-name %q must be of the form NAME=IP", entry)
+		}
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return nil, fmt.Errorf("nbns: --nbns-name %q: invalid IP %q", entry, addr)
+		}
+		if ip.To4() == nil {
+			return nil, fmt.Errorf("nbns: --nbns-name %q: %q is not an IPv4 address (NBNS only supports IPv4)", entry, addr)
+		}
+		names[name] = nbns.Entry{IP: ip, ONT: nbns.NodeB}
 	}
-	flags |= uint16(header.Rescode)
-	return flags
+	return nbns.New(nbns.Options{Names: names}), nil
 }
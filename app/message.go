@@ -0,0 +1,225 @@
+package main
+
+import "fmt"
+
+// RR types this server can parse and build typed RDATA for. Values match
+// the IANA DNS parameter assignments.
+const (
+	TypeA     uint16 = 1
+	TypeNS    uint16 = 2
+	TypeCNAME uint16 = 5
+	TypeSOA   uint16 = 6
+	TypePTR   uint16 = 12
+	TypeMX    uint16 = 15
+	TypeTXT   uint16 = 16
+	TypeAAAA  uint16 = 28
+)
+
+const ClassIN uint16 = 1
+
+type Question struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+func (q Question) pack(w *Writer) error {
+	if err := w.WriteName(q.Name); err != nil {
+		return err
+	}
+	w.WriteU16(q.Type)
+	w.WriteU16(q.Class)
+	return nil
+}
+
+func parseQuestion(buf *Buffer) (Question, error) {
+	name, err := buf.ReadName()
+	if err != nil {
+		return Question{}, fmt.Errorf("question: %w", err)
+	}
+	qtype, err := buf.ReadU16()
+	if err != nil {
+		return Question{}, fmt.Errorf("question: %w", err)
+	}
+	qclass, err := buf.ReadU16()
+	if err != nil {
+		return Question{}, fmt.Errorf("question: %w", err)
+	}
+	return Question{Name: name, Type: qtype, Class: qclass}, nil
+}
+
+// Record is a parsed resource record. Data holds the typed RDATA (see
+// rdata.go); unrecognized RR types are preserved via RawRData rather than
+// dropped.
+type Record struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+	Data  RData
+}
+
+func (r Record) pack(w *Writer) error {
+	if err := w.WriteName(r.Name); err != nil {
+		return err
+	}
+	w.WriteU16(r.Type)
+	w.WriteU16(r.Class)
+	w.WriteU32(r.TTL)
+
+	// RDLENGTH is a placeholder until we know how many bytes Pack wrote,
+	// since a Record's RDATA may itself contain compressed names whose
+	// encoded size isn't known up front.
+	lenPos := w.Len()
+	w.WriteU16(0)
+	start := w.Len()
+	if err := r.Data.Pack(w); err != nil {
+		return err
+	}
+	rdlength := uint16(w.Len() - start)
+	b := w.Bytes()
+	b[lenPos] = byte(rdlength >> 8)
+	b[lenPos+1] = byte(rdlength)
+	return nil
+}
+
+func parseRecord(buf *Buffer) (Record, error) {
+	name, err := buf.ReadName()
+	if err != nil {
+		return Record{}, fmt.Errorf("record: %w", err)
+	}
+	rrtype, err := buf.ReadU16()
+	if err != nil {
+		return Record{}, fmt.Errorf("record: %w", err)
+	}
+	class, err := buf.ReadU16()
+	if err != nil {
+		return Record{}, fmt.Errorf("record: %w", err)
+	}
+	ttl, err := buf.ReadU32()
+	if err != nil {
+		return Record{}, fmt.Errorf("record: %w", err)
+	}
+	rdlength, err := buf.ReadU16()
+	if err != nil {
+		return Record{}, fmt.Errorf("record: %w", err)
+	}
+	data, err := unpackRData(rrtype, rdlength, buf)
+	if err != nil {
+		return Record{}, fmt.Errorf("record: %w", err)
+	}
+	return Record{Name: name, Type: rrtype, Class: class, TTL: ttl, Data: data}, nil
+}
+
+// DNSMessage is a fully parsed DNS packet: header plus all four sections.
+// A leading EDNS0 OPT pseudo-RR in the additional section, if any, is
+// split out into OPT rather than left in Additional.
+type DNSMessage struct {
+	Header     DNSHeader
+	Question   []Question
+	Answer     []Record
+	Authority  []Record
+	Additional []Record
+	OPT        *OPT
+}
+
+// ParseMessage decodes a complete DNS packet, including name-compression
+// pointers that may reach into any section.
+func ParseMessage(data []byte) (*DNSMessage, error) {
+	buf := NewBuffer(data)
+
+	header, err := parseHeader(buf)
+	if err != nil {
+		return nil, fmt.Errorf("message: %w", err)
+	}
+
+	msg := &DNSMessage{Header: header}
+
+	for i := uint16(0); i < header.Questions; i++ {
+		q, err := parseQuestion(buf)
+		if err != nil {
+			return nil, fmt.Errorf("message: question %d: %w", i, err)
+		}
+		msg.Question = append(msg.Question, q)
+	}
+
+	sections := []struct {
+		count uint16
+		dst   *[]Record
+	}{
+		{header.Answers, &msg.Answer},
+		{header.Authoritative_entries, &msg.Authority},
+		{header.Resource_entries, &msg.Additional},
+	}
+	for _, s := range sections {
+		for i := uint16(0); i < s.count; i++ {
+			r, err := parseRecord(buf)
+			if err != nil {
+				return nil, fmt.Errorf("message: %w", err)
+			}
+			*s.dst = append(*s.dst, r)
+		}
+	}
+
+	if err := msg.extractOPT(); err != nil {
+		return nil, fmt.Errorf("message: %w", err)
+	}
+
+	return msg, nil
+}
+
+// extractOPT pulls the first OPT pseudo-RR out of Additional, if any,
+// parsing it into m.OPT and removing it from the section so Additional
+// only ever holds real records.
+func (m *DNSMessage) extractOPT() error {
+	for i, rr := range m.Additional {
+		if rr.Type != TypeOPT {
+			continue
+		}
+		opt, err := newOPTFromRecord(rr)
+		if err != nil {
+			return err
+		}
+		m.OPT = opt
+		m.Additional = append(m.Additional[:i], m.Additional[i+1:]...)
+		return nil
+	}
+	return nil
+}
+
+// Pack serializes the message to wire format, recomputing the section
+// counts in the header from the slice lengths so callers can't forget to
+// keep them in sync.
+func (m *DNSMessage) Pack() ([]byte, error) {
+	additional := m.Additional
+	if m.OPT != nil {
+		optRecord, err := m.OPT.record()
+		if err != nil {
+			return nil, fmt.Errorf("message: %w", err)
+		}
+		additional = append(append([]Record{}, m.Additional...), optRecord)
+	}
+
+	m.Header.Questions = uint16(len(m.Question))
+	m.Header.Answers = uint16(len(m.Answer))
+	m.Header.Authoritative_entries = uint16(len(m.Authority))
+	m.Header.Resource_entries = uint16(len(additional))
+
+	w := NewWriter()
+	if err := packHeader(w, m.Header); err != nil {
+		return nil, err
+	}
+	for _, q := range m.Question {
+		if err := q.pack(w); err != nil {
+			return nil, err
+		}
+	}
+	for _, rr := range [][]Record{m.Answer, m.Authority, additional} {
+		for _, r := range rr {
+			if err := r.pack(w); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return w.Bytes(), nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	header := NewDNSHeader()
+	header.Id = 0x1234
+	header.Response = true
+	header.Recursion_desired = true
+	header.Rescode = NOERROR
+
+	msg := &DNSMessage{
+		Header:   header,
+		Question: []Question{{Name: "example.com", Type: TypeA, Class: ClassIN}},
+		Answer: []Record{
+			{Name: "example.com", Type: TypeA, Class: ClassIN, TTL: 300, Data: ARecord{IP: mustParseIP("93.184.216.34")}},
+		},
+	}
+
+	data, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+
+	parsed, err := ParseMessage(data)
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+
+	if parsed.Header.Id != 0x1234 {
+		t.Errorf("Id = %#x, want 0x1234", parsed.Header.Id)
+	}
+	if len(parsed.Question) != 1 || parsed.Question[0].Name != "example.com" {
+		t.Errorf("Question = %+v, want one question for example.com", parsed.Question)
+	}
+	if len(parsed.Answer) != 1 {
+		t.Fatalf("Answer = %+v, want one record", parsed.Answer)
+	}
+	a, ok := parsed.Answer[0].Data.(ARecord)
+	if !ok {
+		t.Fatalf("Answer[0].Data = %T, want ARecord", parsed.Answer[0].Data)
+	}
+	if a.IP.String() != "93.184.216.34" {
+		t.Errorf("A record IP = %s, want 93.184.216.34", a.IP)
+	}
+}
+
+// TestMessageRoundTripNameCompressionAcrossSections checks that a name
+// repeated in the question and in an answer RR is compressed on Pack and
+// decodes back to the same string on ParseMessage, per RFC 1035 section
+// 4.1.4.
+func TestMessageRoundTripNameCompressionAcrossSections(t *testing.T) {
+	header := NewDNSHeader()
+	header.Response = true
+
+	msg := &DNSMessage{
+		Header:   header,
+		Question: []Question{{Name: "www.example.com", Type: TypeCNAME, Class: ClassIN}},
+		Answer: []Record{
+			{Name: "www.example.com", Type: TypeCNAME, Class: ClassIN, TTL: 60, Data: CNAMERecord{CNAME: "example.com"}},
+			{Name: "example.com", Type: TypeA, Class: ClassIN, TTL: 60, Data: ARecord{IP: mustParseIP("93.184.216.34")}},
+		},
+	}
+
+	data, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+
+	// The second answer's owner name is a suffix of the first, so it
+	// should have compressed down to far less than its own length.
+	if len(data) > 80 {
+		t.Errorf("packed message is %d bytes, expected compression to keep it well under 80", len(data))
+	}
+
+	parsed, err := ParseMessage(data)
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+	if len(parsed.Answer) != 2 {
+		t.Fatalf("Answer = %+v, want two records", parsed.Answer)
+	}
+	if parsed.Answer[0].Name != "www.example.com" {
+		t.Errorf("Answer[0].Name = %q, want %q", parsed.Answer[0].Name, "www.example.com")
+	}
+	if parsed.Answer[1].Name != "example.com" {
+		t.Errorf("Answer[1].Name = %q, want %q", parsed.Answer[1].Name, "example.com")
+	}
+}
+
+func mustParseIP(s string) net.IP {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		panic("bad test IP: " + s)
+	}
+	return ip
+}
@@ -0,0 +1,246 @@
+// Package nbns implements a minimal NBNS (NetBIOS Name Service, RFC
+// 1002 section 4.2) responder: enough to answer name-query broadcasts
+// on UDP/137 from a configurable local name -> IP mapping. It mirrors
+// the main DNS codec's reading/writing style, but NBNS's header flag
+// layout and first-level name encoding differ enough from DNS that it
+// isn't built on the same types.
+package nbns
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// typeNB and classIN are the only QUESTION_TYPE/QUESTION_CLASS values a
+// name-query broadcast uses.
+const (
+	typeNB  uint16 = 0x0020
+	classIN uint16 = 0x0001
+)
+
+// opQuery is the NBNS OPCODE for a name query (RFC 1002 section 4.2.1).
+const opQuery uint8 = 0
+
+// defaultTTL is the TTL this responder attaches to NAME_FLAGS/ADDRESS
+// answers, mirroring the typical 300,000-second (~3.4 day) registration
+// lifetime Windows NBNS responders advertise.
+const defaultTTL = 300000
+
+// nodeType is the owner node type (ONT) advertised in a name's NB_FLAGS,
+// per RFC 1001 section 10.1.
+type nodeType uint8
+
+const (
+	NodeB nodeType = 0 // broadcast
+	NodeP nodeType = 1 // point-to-point
+	NodeM nodeType = 2 // mixed
+	NodeH nodeType = 3 // hybrid
+)
+
+// Entry configures one local name this responder answers for.
+type Entry struct {
+	IP    net.IP
+	Group bool // true for a group name, false for a unique name
+	ONT   nodeType
+}
+
+// Options configures a Responder. Names is keyed by the plain (up to
+// 15-character, case-insensitive) NetBIOS name, not its wire-encoded
+// form.
+type Options struct {
+	Names map[string]Entry
+}
+
+// Responder answers NBNS name-query broadcasts from a fixed local name
+// table. It holds no sockets of its own; callers feed it raw datagrams
+// (e.g. read from a UDP/137 listener) and send back whatever it returns.
+type Responder struct {
+	names map[string]Entry
+}
+
+// New builds a Responder from opts.
+func New(opts Options) *Responder {
+	names := make(map[string]Entry, len(opts.Names))
+	for name, e := range opts.Names {
+		names[normalizeName(name)] = e
+	}
+	return &Responder{names: names}
+}
+
+func normalizeName(name string) string {
+	return strings.ToUpper(strings.TrimRight(name, " \x00"))
+}
+
+// Handle parses a raw NBNS datagram and, if it's a name-query broadcast
+// for a name this Responder knows, returns the wire-format positive
+// name-query response to send back. The second return value is false
+// for anything else (a different opcode, an unknown name, a malformed
+// packet), in which case the caller should send nothing.
+func (r *Responder) Handle(data []byte) ([]byte, bool, error) {
+	hdr, err := parseHeader(data)
+	if err != nil {
+		return nil, false, err
+	}
+	if hdr.Response || hdr.Opcode != opQuery || hdr.Questions == 0 {
+		return nil, false, nil
+	}
+
+	rawName, name, rest, err := decodeQuestionName(data[headerLen:])
+	if err != nil {
+		return nil, false, fmt.Errorf("nbns: %w", err)
+	}
+	if len(rest) < 4 {
+		return nil, false, fmt.Errorf("nbns: question truncated before QTYPE/QCLASS")
+	}
+	qtype := binary.BigEndian.Uint16(rest[0:2])
+	qclass := binary.BigEndian.Uint16(rest[2:4])
+	if qtype != typeNB || qclass != classIN {
+		return nil, false, nil
+	}
+
+	entry, ok := r.names[normalizeName(name)]
+	if !ok {
+		return nil, false, nil
+	}
+
+	return buildResponse(hdr.TransactionID, rawName, entry), true, nil
+}
+
+// header is a parsed NBNS message header. It shares the 12-byte layout
+// DNS uses, but NBNS packs its flags differently: the response bit sits
+// at the top of the first flags byte and the broadcast bit lives in the
+// low nibble of the second, rather than DNS's RA/Z/RCODE placement.
+type header struct {
+	TransactionID uint16
+	Response      bool
+	Opcode        uint8
+	Broadcast     bool
+	Questions     uint16
+	Answers       uint16
+}
+
+const headerLen = 12
+
+func parseHeader(data []byte) (header, error) {
+	if len(data) < headerLen {
+		return header{}, fmt.Errorf("nbns: header of %d bytes shorter than %d", len(data), headerLen)
+	}
+
+	flagsHi := data[2]
+	flagsLo := data[3]
+
+	return header{
+		TransactionID: binary.BigEndian.Uint16(data[0:2]),
+		Response:      flagsHi&0x80 != 0,
+		Opcode:        (flagsHi >> 3) & 0x0F,
+		Broadcast:     (flagsLo>>4)&1 != 0,
+		Questions:     binary.BigEndian.Uint16(data[4:6]),
+		Answers:       binary.BigEndian.Uint16(data[6:8]),
+	}, nil
+}
+
+// decodeQuestionName reads an NBNS QUESTION_NAME: a single length-
+// prefixed label holding the first-level-encoded 16-byte NetBIOS name,
+// terminated by a zero-length label. It returns both the raw 16-byte
+// decoded name (so a reply can echo its exact bytes, suffix included)
+// and that name trimmed to a printable string, plus whatever follows
+// the name in data.
+func decodeQuestionName(data []byte) (raw [16]byte, name string, rest []byte, err error) {
+	if len(data) == 0 {
+		return raw, "", nil, fmt.Errorf("question name: empty")
+	}
+	length := int(data[0])
+	if length != 32 {
+		return raw, "", nil, fmt.Errorf("question name: unexpected label length %d, want 32", length)
+	}
+	if len(data) < 1+32+1 {
+		return raw, "", nil, fmt.Errorf("question name: truncated")
+	}
+	encoded := data[1 : 1+32]
+
+	for i := 0; i < 16; i++ {
+		hi := encoded[2*i]
+		lo := encoded[2*i+1]
+		if hi < 'A' || hi > 'P' || lo < 'A' || lo > 'P' {
+			return raw, "", nil, fmt.Errorf("question name: byte %d has invalid first-level encoding", i)
+		}
+		raw[i] = (hi-'A')<<4 | (lo - 'A')
+	}
+
+	if data[1+32] != 0 {
+		return raw, "", nil, fmt.Errorf("question name: missing root label terminator")
+	}
+
+	return raw, strings.TrimRight(string(raw[:15]), " "), data[1+32+1:], nil
+}
+
+// encodeName first-level-encodes a 16-byte NetBIOS name into the 32
+// ASCII characters NBNS puts on the wire, splitting every nibble into
+// its own 'A'-'P' character (RFC 1001 section 14.1).
+func encodeName(raw [16]byte) []byte {
+	out := make([]byte, 32)
+	for i, b := range raw {
+		out[2*i] = 'A' + (b >> 4)
+		out[2*i+1] = 'A' + (b & 0x0F)
+	}
+	return out
+}
+
+// buildResponse builds a positive NAME_QUERY RESPONSE for rawName
+// (echoed verbatim, so its suffix byte matches the query), answering
+// with a single NB_ADDRESS entry for entry.
+func buildResponse(transactionID uint16, rawName [16]byte, entry Entry) []byte {
+	var buf []byte
+	buf = appendU16(buf, transactionID)
+	buf = appendU16(buf, responseFlags())
+	buf = appendU16(buf, 0) // QDCOUNT
+	buf = appendU16(buf, 1) // ANCOUNT
+	buf = appendU16(buf, 0) // NSCOUNT
+	buf = appendU16(buf, 0) // ARCOUNT
+
+	buf = append(buf, 32)
+	buf = append(buf, encodeName(rawName)...)
+	buf = append(buf, 0) // root label terminator
+
+	buf = appendU16(buf, typeNB)
+	buf = appendU16(buf, classIN)
+	buf = appendU32(buf, defaultTTL)
+
+	ip4 := entry.IP.To4()
+	rdata := make([]byte, 0, 6)
+	rdata = appendU16(rdata, nbFlags(entry))
+	rdata = append(rdata, ip4...)
+
+	buf = appendU16(buf, uint16(len(rdata)))
+	buf = append(buf, rdata...)
+
+	return buf
+}
+
+// responseFlags builds the FLAGS field for a positive, authoritative
+// name-query response: R=1, OPCODE=query, AA=1, RCODE=0.
+func responseFlags() uint16 {
+	const aaBit = 0x0400 // same bit position DNS's AA flag occupies
+	return 0x8000 | uint16(opQuery)<<11 | aaBit
+}
+
+// nbFlags builds a name's NB_FLAGS: the group bit (G) in the top bit and
+// the owner node type (ONT) in the next two (RFC 1001 section 4.2.13).
+func nbFlags(e Entry) uint16 {
+	var flags uint16
+	if e.Group {
+		flags |= 0x8000
+	}
+	flags |= uint16(e.ONT) << 13
+	return flags
+}
+
+func appendU16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendU32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
@@ -0,0 +1,133 @@
+package nbns
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// buildQuery assembles a minimal NBNS name-query broadcast for name,
+// padded/truncated to 16 raw bytes the way a real client would encode
+// it (name left-padded with spaces, suffix byte 0x00 for a workstation
+// name).
+func buildQuery(name string) []byte {
+	var raw [16]byte
+	copy(raw[:], name)
+	for i := len(name); i < 15; i++ {
+		raw[i] = ' '
+	}
+
+	var buf []byte
+	buf = appendU16(buf, 0x1234) // transaction ID
+	buf = appendU16(buf, 0x0010) // FLAGS: broadcast bit set, opcode 0 query
+	buf = appendU16(buf, 1)      // QDCOUNT
+	buf = appendU16(buf, 0)
+	buf = appendU16(buf, 0)
+	buf = appendU16(buf, 0)
+
+	buf = append(buf, 32)
+	buf = append(buf, encodeName(raw)...)
+	buf = append(buf, 0)
+	buf = appendU16(buf, typeNB)
+	buf = appendU16(buf, classIN)
+
+	return buf
+}
+
+func TestHandleAnswersKnownName(t *testing.T) {
+	r := New(Options{Names: map[string]Entry{
+		"WORKSTATION": {IP: net.ParseIP("192.168.1.50"), ONT: NodeB},
+	}})
+
+	reply, ok, err := r.Handle(buildQuery("WORKSTATION"))
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if !ok {
+		t.Fatal("Handle reported no reply for a known name")
+	}
+
+	hdr, err := parseHeader(reply)
+	if err != nil {
+		t.Fatalf("parseHeader(reply): %v", err)
+	}
+	if !hdr.Response {
+		t.Error("reply header Response = false, want true")
+	}
+	if hdr.TransactionID != 0x1234 {
+		t.Errorf("TransactionID = %#x, want 0x1234", hdr.TransactionID)
+	}
+	if hdr.Answers != 1 {
+		t.Errorf("Answers = %d, want 1", hdr.Answers)
+	}
+
+	_, name, rest, err := decodeQuestionName(reply[headerLen:])
+	if err != nil {
+		t.Fatalf("decodeQuestionName(reply): %v", err)
+	}
+	if name != "WORKSTATION" {
+		t.Errorf("answer name = %q, want %q", name, "WORKSTATION")
+	}
+
+	rdlength := binary.BigEndian.Uint16(rest[8:10])
+	rdata := rest[10 : 10+int(rdlength)]
+	if len(rdata) != 6 {
+		t.Fatalf("RDATA length = %d, want 6", len(rdata))
+	}
+	gotIP := net.IP(rdata[2:6])
+	if !gotIP.Equal(net.ParseIP("192.168.1.50")) {
+		t.Errorf("answer IP = %s, want 192.168.1.50", gotIP)
+	}
+}
+
+func TestHandleIgnoresUnknownName(t *testing.T) {
+	r := New(Options{Names: map[string]Entry{
+		"WORKSTATION": {IP: net.ParseIP("192.168.1.50")},
+	}})
+
+	_, ok, err := r.Handle(buildQuery("SOMEOTHERHOST"))
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if ok {
+		t.Error("Handle reported a reply for an unknown name")
+	}
+}
+
+func TestHandleIgnoresResponses(t *testing.T) {
+	r := New(Options{Names: map[string]Entry{
+		"WORKSTATION": {IP: net.ParseIP("192.168.1.50")},
+	}})
+
+	query := buildQuery("WORKSTATION")
+	query[2] |= 0x80 // set the response bit, as if this were itself a reply
+
+	_, ok, err := r.Handle(query)
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if ok {
+		t.Error("Handle answered a packet with the response bit already set")
+	}
+}
+
+func TestNameEncodeDecodeRoundTrip(t *testing.T) {
+	var raw [16]byte
+	copy(raw[:], "FILESERVER     ")
+	raw[15] = 0x20
+
+	encoded := encodeName(raw)
+	data := append([]byte{32}, encoded...)
+	data = append(data, 0, 0xAA, 0xAA) // root terminator + dummy QTYPE/QCLASS bytes
+
+	got, name, _, err := decodeQuestionName(data)
+	if err != nil {
+		t.Fatalf("decodeQuestionName: %v", err)
+	}
+	if got != raw {
+		t.Errorf("decoded raw name = %v, want %v", got, raw)
+	}
+	if name != "FILESERVER" {
+		t.Errorf("name = %q, want %q", name, "FILESERVER")
+	}
+}
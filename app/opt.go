@@ -0,0 +1,145 @@
+package main
+
+import "fmt"
+
+// TypeOPT is the pseudo-RR type that carries EDNS0 metadata, per RFC
+// 6891. It never appears in Answer/Authority and is pulled out of the
+// additional section into DNSMessage.OPT rather than left as a plain
+// Record.
+const TypeOPT uint16 = 41
+
+// codeEDE is the EDNS0 option code for Extended DNS Errors (RFC 8914).
+const codeEDE uint16 = 15
+
+// Extended DNS Error info codes this server knows how to produce. Values
+// match the IANA "Extended DNS Error Codes" registry.
+const (
+	EDEOther       uint16 = 0
+	EDEDNSSECBogus uint16 = 6
+	EDEBlocked     uint16 = 15
+	EDEFiltered    uint16 = 17
+)
+
+// EDNSOption is a single EDNS0 option from an OPT record's RDATA: an
+// OPTION-CODE/OPTION-LENGTH/OPTION-DATA triple whose DATA this server
+// doesn't otherwise understand. EDE options are additionally exposed
+// pre-parsed via OPT.EDE.
+type EDNSOption struct {
+	Code uint16
+	Data []byte
+}
+
+// EDNSEDE is a parsed Extended DNS Error (RFC 8914) option: a machine
+// readable InfoCode plus an optional human-readable ExtraText.
+type EDNSEDE struct {
+	InfoCode  uint16
+	ExtraText string
+}
+
+// OPT holds the EDNS0 metadata carried by a query or reply's OPT
+// pseudo-RR.
+type OPT struct {
+	// UDPSize is the sender's advertised UDP payload size (RFC 6891
+	// section 6.1.2), carried in the OPT record's CLASS field.
+	UDPSize uint16
+	// ExtendedRcode is the upper 8 bits of the 12-bit extended RCODE;
+	// the lower 4 bits remain in DNSHeader.Rescode.
+	ExtendedRcode uint8
+	// Version is the EDNS version; this server only understands 0.
+	Version uint8
+	// DO is the DNSSEC OK bit (RFC 3225).
+	DO bool
+	// Options holds every option from the record's RDATA that this
+	// server doesn't parse into a dedicated field.
+	Options []EDNSOption
+	// EDE is the Extended DNS Error attached to this message, if any.
+	EDE *EDNSEDE
+}
+
+// newOPTFromRecord reinterprets a parsed OPT pseudo-RR's generic fields
+// (CLASS, TTL, RDATA) as EDNS0 metadata.
+func newOPTFromRecord(rr Record) (*OPT, error) {
+	raw, ok := rr.Data.(RawRData)
+	if !ok {
+		return nil, fmt.Errorf("opt: unexpected RDATA representation %T", rr.Data)
+	}
+
+	opt := &OPT{
+		UDPSize:       rr.Class,
+		ExtendedRcode: uint8(rr.TTL >> 24),
+		Version:       uint8(rr.TTL >> 16),
+		DO:            rr.TTL&0x8000 != 0,
+	}
+
+	buf := NewBuffer(raw.Data)
+	for buf.Pos() < len(raw.Data) {
+		code, err := buf.ReadU16()
+		if err != nil {
+			return nil, fmt.Errorf("opt: option code: %w", err)
+		}
+		length, err := buf.ReadU16()
+		if err != nil {
+			return nil, fmt.Errorf("opt: option length: %w", err)
+		}
+		data, err := buf.ReadBytes(int(length))
+		if err != nil {
+			return nil, fmt.Errorf("opt: option data: %w", err)
+		}
+		data = append([]byte{}, data...)
+
+		if code == codeEDE {
+			ede, err := parseEDE(data)
+			if err != nil {
+				return nil, fmt.Errorf("opt: %w", err)
+			}
+			opt.EDE = ede
+			continue
+		}
+		opt.Options = append(opt.Options, EDNSOption{Code: code, Data: data})
+	}
+
+	return opt, nil
+}
+
+// parseEDE decodes an EDE option's DATA: a 2-byte INFO-CODE followed by
+// an optional UTF-8 EXTRA-TEXT (RFC 8914 section 2).
+func parseEDE(data []byte) (*EDNSEDE, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("EDE option of %d bytes shorter than INFO-CODE", len(data))
+	}
+	return &EDNSEDE{
+		InfoCode:  uint16(data[0])<<8 | uint16(data[1]),
+		ExtraText: string(data[2:]),
+	}, nil
+}
+
+// record builds the OPT pseudo-RR that represents opt on the wire.
+func (opt *OPT) record() (Record, error) {
+	w := NewWriter()
+	for _, o := range opt.Options {
+		w.WriteU16(o.Code)
+		w.WriteU16(uint16(len(o.Data)))
+		w.WriteBytes(o.Data)
+	}
+	if opt.EDE != nil {
+		payload := append([]byte{byte(opt.EDE.InfoCode >> 8), byte(opt.EDE.InfoCode)}, []byte(opt.EDE.ExtraText)...)
+		w.WriteU16(codeEDE)
+		w.WriteU16(uint16(len(payload)))
+		w.WriteBytes(payload)
+	}
+
+	var ttl uint32
+	ttl |= uint32(opt.ExtendedRcode) << 24
+	ttl |= uint32(opt.Version) << 16
+	if opt.DO {
+		ttl |= 0x8000
+	}
+
+	return Record{
+		Name:  "",
+		Type:  TypeOPT,
+		Class: opt.UDPSize,
+		TTL:   ttl,
+		Data:  RawRData{RRType: TypeOPT, Data: w.Bytes()},
+	}, nil
+}
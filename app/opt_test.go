@@ -0,0 +1,112 @@
+package main
+
+import "testing"
+
+func TestOPTRoundTrip(t *testing.T) {
+	msg := &DNSMessage{
+		Header:   NewDNSHeader(),
+		Question: []Question{{Name: "example.com", Type: TypeA, Class: ClassIN}},
+		OPT: &OPT{
+			UDPSize:       4096,
+			ExtendedRcode: 1,
+			Version:       0,
+			DO:            true,
+			Options:       []EDNSOption{{Code: 8, Data: []byte{0x00, 0x01}}}, // ECS, opaque here
+		},
+	}
+
+	data, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+
+	parsed, err := ParseMessage(data)
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+
+	if parsed.OPT == nil {
+		t.Fatal("OPT = nil, want non-nil")
+	}
+	if parsed.OPT.UDPSize != 4096 {
+		t.Errorf("UDPSize = %d, want 4096", parsed.OPT.UDPSize)
+	}
+	if parsed.OPT.ExtendedRcode != 1 {
+		t.Errorf("ExtendedRcode = %d, want 1", parsed.OPT.ExtendedRcode)
+	}
+	if !parsed.OPT.DO {
+		t.Errorf("DO = false, want true")
+	}
+	if len(parsed.OPT.Options) != 1 || parsed.OPT.Options[0].Code != 8 {
+		t.Errorf("Options = %+v, want one option with code 8", parsed.OPT.Options)
+	}
+	if len(parsed.Additional) != 0 {
+		t.Errorf("Additional = %+v, want the OPT record split out, not left in Additional", parsed.Additional)
+	}
+}
+
+func TestOPTRoundTripEDE(t *testing.T) {
+	msg := &DNSMessage{
+		Header:   NewDNSHeader(),
+		Question: []Question{{Name: "blocked.example", Type: TypeA, Class: ClassIN}},
+		OPT: &OPT{
+			UDPSize: 512,
+			EDE:     &EDNSEDE{InfoCode: EDEBlocked, ExtraText: "blocked by rule \"evil.com\""},
+		},
+	}
+
+	data, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+
+	parsed, err := ParseMessage(data)
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+
+	if parsed.OPT == nil || parsed.OPT.EDE == nil {
+		t.Fatal("EDE = nil, want non-nil")
+	}
+	if parsed.OPT.EDE.InfoCode != EDEBlocked {
+		t.Errorf("InfoCode = %d, want %d", parsed.OPT.EDE.InfoCode, EDEBlocked)
+	}
+	if parsed.OPT.EDE.ExtraText != `blocked by rule "evil.com"` {
+		t.Errorf("ExtraText = %q, want %q", parsed.OPT.EDE.ExtraText, `blocked by rule "evil.com"`)
+	}
+}
+
+func TestNoOPTLeavesAdditionalUntouched(t *testing.T) {
+	msg := &DNSMessage{
+		Header:   NewDNSHeader(),
+		Question: []Question{{Name: "example.com", Type: TypeA, Class: ClassIN}},
+	}
+
+	data, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+
+	parsed, err := ParseMessage(data)
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+	if parsed.OPT != nil {
+		t.Errorf("OPT = %+v, want nil", parsed.OPT)
+	}
+}
+
+func TestEchoOPTStripsReplyOPTWhenQueryHasNone(t *testing.T) {
+	r := &Resolver{}
+	query := &DNSMessage{Header: NewDNSHeader()}
+	reply := &DNSMessage{
+		Header: NewDNSHeader(),
+		OPT:    &OPT{EDE: &EDNSEDE{InfoCode: EDEBlocked, ExtraText: "blocked by rule \"evil.com\""}},
+	}
+
+	got := r.echoOPT(query, reply)
+
+	if got.OPT != nil {
+		t.Errorf("OPT = %+v, want nil: a responder must not add an OPT record a query didn't have", got.OPT)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// RData is the parsed resource-record payload. Each DNS RR type that this
+// server understands gets its own struct; anything else falls back to
+// RawRData so the record can still be round-tripped without being
+// understood.
+type RData interface {
+	// Type returns the RR type this RData encodes, matching the TYPE
+	// values in the record header it was parsed from.
+	Type() uint16
+	// Pack writes the wire-format RDATA (without the length prefix) to w.
+	Pack(w *Writer) error
+}
+
+type ARecord struct{ IP net.IP }
+
+func (r ARecord) Type() uint16 { return TypeA }
+func (r ARecord) Pack(w *Writer) error {
+	ip := r.IP.To4()
+	if ip == nil {
+		return fmt.Errorf("rdata: %v is not an IPv4 address", r.IP)
+	}
+	w.WriteBytes(ip)
+	return nil
+}
+
+type AAAARecord struct{ IP net.IP }
+
+func (r AAAARecord) Type() uint16 { return TypeAAAA }
+func (r AAAARecord) Pack(w *Writer) error {
+	ip := r.IP.To16()
+	if ip == nil {
+		return fmt.Errorf("rdata: %v is not an IPv6 address", r.IP)
+	}
+	w.WriteBytes(ip)
+	return nil
+}
+
+type NSRecord struct{ NS string }
+
+func (r NSRecord) Type() uint16         { return TypeNS }
+func (r NSRecord) Pack(w *Writer) error { return w.WriteName(r.NS) }
+
+type CNAMERecord struct{ CNAME string }
+
+func (r CNAMERecord) Type() uint16         { return TypeCNAME }
+func (r CNAMERecord) Pack(w *Writer) error { return w.WriteName(r.CNAME) }
+
+type PTRRecord struct{ PTR string }
+
+func (r PTRRecord) Type() uint16         { return TypePTR }
+func (r PTRRecord) Pack(w *Writer) error { return w.WriteName(r.PTR) }
+
+type MXRecord struct {
+	Preference uint16
+	MX         string
+}
+
+func (r MXRecord) Type() uint16 { return TypeMX }
+func (r MXRecord) Pack(w *Writer) error {
+	w.WriteU16(r.Preference)
+	return w.WriteName(r.MX)
+}
+
+type TXTRecord struct{ Txt []string }
+
+func (r TXTRecord) Type() uint16 { return TypeTXT }
+func (r TXTRecord) Pack(w *Writer) error {
+	for _, s := range r.Txt {
+		if len(s) > 255 {
+			return fmt.Errorf("rdata: TXT chunk of %d bytes exceeds 255", len(s))
+		}
+		w.WriteU8(uint8(len(s)))
+		w.WriteBytes([]byte(s))
+	}
+	return nil
+}
+
+type SOARecord struct {
+	MName   string
+	RName   string
+	Serial  uint32
+	Refresh uint32
+	Retry   uint32
+	Expire  uint32
+	Minimum uint32
+}
+
+func (r SOARecord) Type() uint16 { return TypeSOA }
+func (r SOARecord) Pack(w *Writer) error {
+	if err := w.WriteName(r.MName); err != nil {
+		return err
+	}
+	if err := w.WriteName(r.RName); err != nil {
+		return err
+	}
+	w.WriteU32(r.Serial)
+	w.WriteU32(r.Refresh)
+	w.WriteU32(r.Retry)
+	w.WriteU32(r.Expire)
+	w.WriteU32(r.Minimum)
+	return nil
+}
+
+// RawRData holds the unparsed RDATA for a record type this server doesn't
+// model explicitly. It still round-trips correctly.
+type RawRData struct {
+	RRType uint16
+	Data   []byte
+}
+
+func (r RawRData) Type() uint16 { return r.RRType }
+func (r RawRData) Pack(w *Writer) error {
+	w.WriteBytes(r.Data)
+	return nil
+}
+
+// unpackRData reads rdlength bytes of RDATA for the given type starting at
+// buf's current position. Names embedded in RDATA (NS, CNAME, MX, PTR,
+// SOA) are read with the normal compression-aware ReadName, since a
+// pointer inside RDATA addresses the whole packet, not just the RDATA
+// slice; rdlength is still used to validate how much the record claims to
+// occupy.
+func unpackRData(rrtype uint16, rdlength uint16, buf *Buffer) (RData, error) {
+	start := buf.Pos()
+
+	switch rrtype {
+	case TypeA:
+		b, err := buf.ReadBytes(4)
+		if err != nil {
+			return nil, err
+		}
+		return ARecord{IP: net.IP(append([]byte{}, b...))}, nil
+
+	case TypeAAAA:
+		b, err := buf.ReadBytes(16)
+		if err != nil {
+			return nil, err
+		}
+		return AAAARecord{IP: net.IP(append([]byte{}, b...))}, nil
+
+	case TypeNS:
+		name, err := buf.ReadName()
+		if err != nil {
+			return nil, err
+		}
+		return NSRecord{NS: name}, nil
+
+	case TypeCNAME:
+		name, err := buf.ReadName()
+		if err != nil {
+			return nil, err
+		}
+		return CNAMERecord{CNAME: name}, nil
+
+	case TypePTR:
+		name, err := buf.ReadName()
+		if err != nil {
+			return nil, err
+		}
+		return PTRRecord{PTR: name}, nil
+
+	case TypeMX:
+		pref, err := buf.ReadU16()
+		if err != nil {
+			return nil, err
+		}
+		name, err := buf.ReadName()
+		if err != nil {
+			return nil, err
+		}
+		return MXRecord{Preference: pref, MX: name}, nil
+
+	case TypeTXT:
+		var chunks []string
+		for buf.Pos() < start+int(rdlength) {
+			n, err := buf.ReadU8()
+			if err != nil {
+				return nil, err
+			}
+			b, err := buf.ReadBytes(int(n))
+			if err != nil {
+				return nil, err
+			}
+			chunks = append(chunks, string(b))
+		}
+		return TXTRecord{Txt: chunks}, nil
+
+	case TypeSOA:
+		mname, err := buf.ReadName()
+		if err != nil {
+			return nil, err
+		}
+		rname, err := buf.ReadName()
+		if err != nil {
+			return nil, err
+		}
+		serial, err := buf.ReadU32()
+		if err != nil {
+			return nil, err
+		}
+		refresh, err := buf.ReadU32()
+		if err != nil {
+			return nil, err
+		}
+		retry, err := buf.ReadU32()
+		if err != nil {
+			return nil, err
+		}
+		expire, err := buf.ReadU32()
+		if err != nil {
+			return nil, err
+		}
+		minimum, err := buf.ReadU32()
+		if err != nil {
+			return nil, err
+		}
+		return SOARecord{
+			MName: mname, RName: rname,
+			Serial: serial, Refresh: refresh, Retry: retry,
+			Expire: expire, Minimum: minimum,
+		}, nil
+
+	default:
+		b, err := buf.ReadBytes(int(rdlength))
+		if err != nil {
+			return nil, err
+		}
+		return RawRData{RRType: rrtype, Data: append([]byte{}, b...)}, nil
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/ndrpp/codecrafters-dns-server-go/blocklist"
+	"github.com/ndrpp/codecrafters-dns-server-go/cache"
+)
+
+// blocklistTTL is the TTL attached to synthesized null/custom-ip
+// blocklist answers. Short enough that an unblocked rule fix (hot
+// reload) is reflected quickly, long enough to absorb a busy client's
+// retries.
+const blocklistTTL = 300
+
+// ResolverMode selects how Resolver.Resolve answers a query.
+type ResolverMode string
+
+const (
+	// ModeForward relays every query to the configured upstream pool,
+	// acting as a simple stub forwarder.
+	ModeForward ResolverMode = "forward"
+	// ModeRecursive walks the delegation chain itself starting from the
+	// root servers, never contacting the configured upstreams.
+	ModeRecursive ResolverMode = "recursive"
+)
+
+// maxReferrals bounds how many NS delegations resolveIterative will
+// follow for a single query, guarding against referral loops between
+// misconfigured servers.
+const maxReferrals = 16
+
+// rootHints are well-known root server addresses used to seed recursive
+// resolution. Only a handful are needed since any one of them can answer
+// the first referral.
+var rootHints = []string{
+	"198.41.0.4",     // a.root-servers.net
+	"199.9.14.201",   // b.root-servers.net
+	"192.33.4.12",    // c.root-servers.net
+	"199.7.91.13",    // d.root-servers.net
+	"192.203.230.10", // e.root-servers.net
+}
+
+// Resolver turns an incoming query into a reply, either by forwarding to
+// a fixed upstream pool or by performing iterative resolution itself.
+type Resolver struct {
+	Mode       ResolverMode
+	Upstreams  *UpstreamPool
+	Cache      *cache.Cache
+	Blocklist  *blocklist.List
+	LocalZones *LocalZones
+}
+
+// NewResolver builds a Resolver. upstreams may be nil when mode is
+// ModeRecursive, since recursive resolution ignores the configured pool.
+// c, bl, and lz may each be nil to run without a response cache,
+// blocklist, or local-zone table, respectively.
+func NewResolver(mode ResolverMode, upstreams *UpstreamPool, c *cache.Cache, bl *blocklist.List, lz *LocalZones) *Resolver {
+	return &Resolver{Mode: mode, Upstreams: upstreams, Cache: c, Blocklist: bl, LocalZones: lz}
+}
+
+// Resolve answers msg, which must carry exactly one question, the way
+// nearly all real-world resolvers restrict themselves. The reply always
+// carries the original transaction ID and the question section the
+// client sent. A blocklist hit is answered first, ahead of local zones,
+// the cache, and upstream/iterative resolution; a local-zone hit comes
+// next, answered authoritatively straight from the configured table
+// without ever touching the cache or upstreams; otherwise a cache hit
+// bypasses the remaining upstream/iterative resolution entirely, and a
+// miss is resolved normally and, unless the question matches
+// --no-cache-pattern, the result is written back for next time.
+func (r *Resolver) Resolve(msg *DNSMessage) *DNSMessage {
+	if len(msg.Question) != 1 {
+		return r.echoOPT(msg, r.errorReply(msg, FORMERR, nil))
+	}
+	q := msg.Question[0]
+
+	if r.Blocklist != nil {
+		if rule, blocked := r.Blocklist.Match(q.Name); blocked {
+			return r.echoOPT(msg, r.blockedReply(msg, q, rule))
+		}
+	}
+
+	if r.LocalZones != nil {
+		if rrs, ok := r.LocalZones.Lookup(q.Name); ok {
+			return r.echoOPT(msg, r.localZoneReply(msg, q, rrs))
+		}
+	}
+
+	if r.Cache != nil && !r.Cache.Skip(q.Name) {
+		if reply, ok := r.fromCache(msg, q); ok {
+			return r.echoOPT(msg, reply)
+		}
+	}
+
+	var reply *DNSMessage
+	var err error
+
+	switch r.Mode {
+	case ModeRecursive:
+		reply, err = r.resolveIterative(q)
+	default:
+		if r.Upstreams == nil {
+			return r.echoOPT(msg, r.errorReply(msg, SERVFAIL, &EDNSEDE{InfoCode: EDEOther, ExtraText: "no upstream configured"}))
+		}
+		reply, err = r.Upstreams.Exchange(msg)
+	}
+
+	if err != nil {
+		return r.echoOPT(msg, r.errorReply(msg, SERVFAIL, &EDNSEDE{InfoCode: EDEOther, ExtraText: err.Error()}))
+	}
+
+	if r.Cache != nil && !r.Cache.Skip(q.Name) {
+		r.insertCache(q, reply)
+	}
+
+	reply.Header.Id = msg.Header.Id
+	reply.Header.Recursion_desired = msg.Header.Recursion_desired
+	reply.Header.Recursion_available = true
+	reply.Question = msg.Question
+	return r.echoOPT(msg, reply)
+}
+
+// echoOPT attaches an OPT record to reply mirroring query's advertised
+// UDP payload size when query carried one, per RFC 6891 section 7. The
+// server never validates DNSSEC, so the DO bit is never set on replies.
+// If query carried no OPT, any OPT reply already has (e.g. one set by
+// blockedReply or errorReply before the query is known) is stripped:
+// section 6.1.1 forbids a responder from adding one the request didn't.
+func (r *Resolver) echoOPT(query, reply *DNSMessage) *DNSMessage {
+	if query.OPT == nil {
+		reply.OPT = nil
+		return reply
+	}
+	if reply.OPT == nil {
+		reply.OPT = &OPT{UDPSize: query.OPT.UDPSize}
+	} else {
+		reply.OPT.UDPSize = query.OPT.UDPSize
+	}
+	return reply
+}
+
+// fromCache answers msg straight from the cache, if a live entry exists
+// for its question.
+func (r *Resolver) fromCache(msg *DNSMessage, q Question) (*DNSMessage, bool) {
+	entry, ok := r.Cache.Get(cacheKey(q))
+	if !ok {
+		return nil, false
+	}
+
+	header := msg.Header
+	header.Response = true
+	header.Recursion_available = true
+
+	if entry.Negative {
+		header.Rescode = ResultCode(entry.Rescode)
+		return &DNSMessage{Header: header, Question: msg.Question}, true
+	}
+
+	header.Rescode = NOERROR
+	reply := &DNSMessage{Header: header, Question: msg.Question}
+	for _, cached := range entry.RRs {
+		rr, err := fromCacheRR(cached)
+		if err != nil {
+			return nil, false
+		}
+		reply.Answer = append(reply.Answer, rr)
+	}
+	return reply, true
+}
+
+// insertCache stores reply's answer under q, or a negative entry keyed
+// off the authority section's SOA MINIMUM when the upstream reported
+// NXDOMAIN or an empty NOERROR (NODATA), per RFC 2308. The original
+// rescode is kept with the entry so a later cache hit replays NXDOMAIN
+// and NODATA distinctly instead of collapsing both to NXDOMAIN.
+func (r *Resolver) insertCache(q Question, reply *DNSMessage) {
+	if reply.Header.Rescode == NXDOMAIN || (reply.Header.Rescode == NOERROR && len(reply.Answer) == 0) {
+		if minimum, ok := soaMinimum(reply.Authority); ok {
+			r.Cache.Put(cacheKey(q), cache.Entry{
+				Negative:    true,
+				Rescode:     uint16(reply.Header.Rescode),
+				NegativeTTL: minimum,
+			})
+		}
+		return
+	}
+
+	if reply.Header.Rescode != NOERROR {
+		return
+	}
+
+	var rrs []cache.RR
+	for _, rr := range reply.Answer {
+		cached, err := toCacheRR(rr)
+		if err != nil {
+			return
+		}
+		rrs = append(rrs, cached)
+	}
+	r.Cache.Put(cacheKey(q), cache.Entry{RRs: rrs})
+}
+
+// localZoneReply synthesizes the authoritative reply for a local-zone
+// hit on q, filtering rrs (every record configured for q.Name, of
+// whatever type) down to the ones matching q.Type. A zone configured
+// for the name but with no record of the requested type answers NOERROR
+// with no answers (NODATA), the same as an upstream would for a name
+// that exists but lacks that type.
+func (r *Resolver) localZoneReply(msg *DNSMessage, q Question, rrs []Record) *DNSMessage {
+	header := msg.Header
+	header.Response = true
+	header.Authoritative_answer = true
+	header.Recursion_available = r.Mode == ModeRecursive || r.Upstreams != nil
+	header.Rescode = NOERROR
+
+	reply := &DNSMessage{Header: header, Question: msg.Question}
+	for _, rr := range rrs {
+		if rr.Type == q.Type {
+			reply.Answer = append(reply.Answer, rr)
+		}
+	}
+	return reply
+}
+
+// blockedReply synthesizes the reply for a blocklist hit on q, matched by
+// rule, according to r.Blocklist's configured policy.
+func (r *Resolver) blockedReply(msg *DNSMessage, q Question, rule string) *DNSMessage {
+	fmt.Printf("blocklist: blocked %s (rule %q)\n", q.Name, rule)
+
+	header := msg.Header
+	header.Response = true
+	header.Recursion_available = r.Mode == ModeRecursive || r.Upstreams != nil
+	reply := &DNSMessage{Question: msg.Question}
+
+	var rr Record
+	var ok bool
+	switch r.Blocklist.Policy() {
+	case blocklist.PolicyRefused:
+		header.Rescode = REFUSED
+	case blocklist.PolicyNull:
+		rr, ok = nullRecord(q)
+	case blocklist.PolicyCustomIP:
+		rr, ok = customIPRecord(q, r.Blocklist.CustomIP())
+	default:
+		header.Rescode = NXDOMAIN
+	}
+
+	if ok {
+		header.Rescode = NOERROR
+		reply.Answer = []Record{rr}
+	} else if header.Rescode == NOERROR {
+		header.Rescode = NXDOMAIN
+	}
+
+	reply.Header = header
+	reply.OPT = &OPT{EDE: &EDNSEDE{InfoCode: EDEBlocked, ExtraText: fmt.Sprintf("blocked by rule %q", rule)}}
+	return reply
+}
+
+// nullRecord builds the synthesized 0.0.0.0/:: answer for PolicyNull. It
+// only has an answer for A and AAAA queries; anything else falls back to
+// NXDOMAIN.
+func nullRecord(q Question) (Record, bool) {
+	switch q.Type {
+	case TypeA:
+		return Record{Name: q.Name, Type: TypeA, Class: ClassIN, TTL: blocklistTTL, Data: ARecord{IP: net.IPv4zero}}, true
+	case TypeAAAA:
+		return Record{Name: q.Name, Type: TypeAAAA, Class: ClassIN, TTL: blocklistTTL, Data: AAAARecord{IP: net.IPv6zero}}, true
+	default:
+		return Record{}, false
+	}
+}
+
+// customIPRecord builds the synthesized answer for PolicyCustomIP,
+// matching ip's address family to the query type. It has no answer if ip
+// is unset or its family doesn't match q.Type.
+func customIPRecord(q Question, ip net.IP) (Record, bool) {
+	if ip == nil {
+		return Record{}, false
+	}
+	switch q.Type {
+	case TypeA:
+		if v4 := ip.To4(); v4 != nil {
+			return Record{Name: q.Name, Type: TypeA, Class: ClassIN, TTL: blocklistTTL, Data: ARecord{IP: v4}}, true
+		}
+	case TypeAAAA:
+		if ip.To4() == nil {
+			return Record{Name: q.Name, Type: TypeAAAA, Class: ClassIN, TTL: blocklistTTL, Data: AAAARecord{IP: ip}}, true
+		}
+	}
+	return Record{}, false
+}
+
+// errorReply builds a bare error response to msg carrying code, and, if
+// ede is non-nil, an attached Extended DNS Error explaining why.
+func (r *Resolver) errorReply(msg *DNSMessage, code ResultCode, ede *EDNSEDE) *DNSMessage {
+	header := msg.Header
+	header.Response = true
+	header.Rescode = code
+	header.Recursion_available = r.Mode == ModeRecursive || r.Upstreams != nil
+	reply := &DNSMessage{Header: header, Question: msg.Question}
+	if ede != nil {
+		reply.OPT = &OPT{EDE: ede}
+	}
+	return reply
+}
+
+// resolveIterative performs classic iterative resolution: ask a
+// nameserver, and if it doesn't have the answer but delegates further
+// (an NS referral with glue A records in the additional section), move
+// on to the delegated servers. It gives up, returning the best reply
+// seen so far, if a referral has no usable glue — following an
+// unglued NS would require a second, nested resolution this simple
+// walker doesn't perform.
+func (r *Resolver) resolveIterative(q Question) (*DNSMessage, error) {
+	servers := rootHints
+
+	for depth := 0; depth < maxReferrals; depth++ {
+		pool, err := parseUpstreamList(servers, RoundRobin)
+		if err != nil {
+			return nil, fmt.Errorf("resolver: %w", err)
+		}
+
+		query := &DNSMessage{
+			Header:   DNSHeader{Recursion_desired: false},
+			Question: []Question{q},
+		}
+		reply, err := pool.Exchange(query)
+		if err != nil {
+			return nil, fmt.Errorf("resolver: depth %d: %w", depth, err)
+		}
+
+		if len(reply.Answer) > 0 || reply.Header.Rescode == NXDOMAIN {
+			return reply, nil
+		}
+
+		next := glueAddresses(reply.Authority, reply.Additional)
+		if len(next) == 0 {
+			return reply, nil
+		}
+		servers = next
+	}
+
+	return nil, fmt.Errorf("resolver: exceeded %d referrals", maxReferrals)
+}
+
+// glueAddresses extracts the IPv4 glue addresses for the nameservers
+// delegated in authority's NS records, matching them up against the A
+// records carried alongside in additional.
+func glueAddresses(authority, additional []Record) []string {
+	nsNames := make(map[string]struct{})
+	for _, rr := range authority {
+		if ns, ok := rr.Data.(NSRecord); ok {
+			nsNames[ns.NS] = struct{}{}
+		}
+	}
+	if len(nsNames) == 0 {
+		return nil
+	}
+
+	var addrs []string
+	for _, rr := range additional {
+		a, ok := rr.Data.(ARecord)
+		if !ok {
+			continue
+		}
+		if _, wanted := nsNames[rr.Name]; wanted {
+			addrs = append(addrs, a.IP.String())
+		}
+	}
+	return addrs
+}
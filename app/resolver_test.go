@@ -0,0 +1,215 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ndrpp/codecrafters-dns-server-go/blocklist"
+	"github.com/ndrpp/codecrafters-dns-server-go/cache"
+)
+
+// scriptedUpstream is an Upstream whose reply is built by a caller-supplied
+// function, for tests that need more than fakeUpstream's bare rescode (a
+// full answer or an authority section with an SOA, say).
+type scriptedUpstream struct {
+	addr  string
+	reply func(msg *DNSMessage) *DNSMessage
+	calls int
+}
+
+func (s *scriptedUpstream) Address() string { return s.addr }
+func (s *scriptedUpstream) Exchange(msg *DNSMessage) (*DNSMessage, error) {
+	s.calls++
+	return s.reply(msg), nil
+}
+
+func newTestResolver(t *testing.T, up Upstream, bl *blocklist.List, lz *LocalZones) (*Resolver, *cache.Cache) {
+	t.Helper()
+	c, err := cache.Open(cache.Options{})
+	if err != nil {
+		t.Fatalf("cache.Open: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	var pool *UpstreamPool
+	if up != nil {
+		pool, err = NewUpstreamPool(RoundRobin, []Upstream{up})
+		if err != nil {
+			t.Fatalf("NewUpstreamPool: %v", err)
+		}
+	}
+	return NewResolver(ModeForward, pool, c, bl, lz), c
+}
+
+func soaReply(header DNSHeader) *DNSMessage {
+	return &DNSMessage{
+		Header: header,
+		Authority: []Record{
+			{Name: "example.com", Type: TypeSOA, Class: ClassIN, TTL: 3600, Data: SOARecord{
+				MName: "ns1.example.com", RName: "hostmaster.example.com", Minimum: 120,
+			}},
+		},
+	}
+}
+
+func TestResolveCacheHitBypassesUpstream(t *testing.T) {
+	up := &scriptedUpstream{addr: "up", reply: func(msg *DNSMessage) *DNSMessage {
+		t.Fatal("upstream was queried, want the cache hit to bypass it entirely")
+		return nil
+	}}
+	r, c := newTestResolver(t, up, nil, nil)
+
+	q := Question{Name: "cached.example.com", Type: TypeA, Class: ClassIN}
+	rr, err := toCacheRR(Record{Name: q.Name, Type: TypeA, Class: ClassIN, TTL: 300, Data: ARecord{IP: mustParseIP("1.2.3.4")}})
+	if err != nil {
+		t.Fatalf("toCacheRR: %v", err)
+	}
+	if err := c.Put(cacheKey(q), cache.Entry{RRs: []cache.RR{rr}}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	msg := &DNSMessage{Header: NewDNSHeader(), Question: []Question{q}}
+	reply := r.Resolve(msg)
+
+	if up.calls != 0 {
+		t.Errorf("upstream calls = %d, want 0", up.calls)
+	}
+	if reply.Header.Rescode != NOERROR {
+		t.Errorf("Rescode = %v, want NOERROR", reply.Header.Rescode)
+	}
+	if len(reply.Answer) != 1 {
+		t.Fatalf("Answer = %+v, want one record from the cache", reply.Answer)
+	}
+	a, ok := reply.Answer[0].Data.(ARecord)
+	if !ok || a.IP.String() != "1.2.3.4" {
+		t.Errorf("Answer[0].Data = %+v, want the cached A record for 1.2.3.4", reply.Answer[0].Data)
+	}
+}
+
+func TestResolveInsertsNegativeCacheOnNXDOMAIN(t *testing.T) {
+	up := &scriptedUpstream{reply: func(msg *DNSMessage) *DNSMessage {
+		header := msg.Header
+		header.Response = true
+		header.Rescode = NXDOMAIN
+		return soaReply(header)
+	}}
+	r, c := newTestResolver(t, up, nil, nil)
+
+	q := Question{Name: "nxdomain.example.com", Type: TypeA, Class: ClassIN}
+	msg := &DNSMessage{Header: NewDNSHeader(), Question: []Question{q}}
+
+	first := r.Resolve(msg)
+	if first.Header.Rescode != NXDOMAIN {
+		t.Fatalf("Rescode = %v, want NXDOMAIN", first.Header.Rescode)
+	}
+
+	entry, ok := c.Get(cacheKey(q))
+	if !ok || !entry.Negative || entry.Rescode != uint16(NXDOMAIN) {
+		t.Fatalf("cached entry = %+v, ok=%v, want a negative entry with Rescode NXDOMAIN", entry, ok)
+	}
+
+	second := r.Resolve(msg)
+	if second.Header.Rescode != NXDOMAIN {
+		t.Errorf("Rescode on replay = %v, want NXDOMAIN", second.Header.Rescode)
+	}
+	if up.calls != 1 {
+		t.Errorf("upstream calls = %d, want 1 (second query should be answered from the negative cache)", up.calls)
+	}
+}
+
+func TestResolveInsertsNegativeCacheOnNODATA(t *testing.T) {
+	up := &scriptedUpstream{reply: func(msg *DNSMessage) *DNSMessage {
+		header := msg.Header
+		header.Response = true
+		header.Rescode = NOERROR
+		return soaReply(header)
+	}}
+	r, c := newTestResolver(t, up, nil, nil)
+
+	q := Question{Name: "nodata.example.com", Type: TypeAAAA, Class: ClassIN}
+	msg := &DNSMessage{Header: NewDNSHeader(), Question: []Question{q}}
+
+	first := r.Resolve(msg)
+	if first.Header.Rescode != NOERROR || len(first.Answer) != 0 {
+		t.Fatalf("reply = %+v, want NOERROR with no answers (NODATA)", first)
+	}
+
+	entry, ok := c.Get(cacheKey(q))
+	if !ok || !entry.Negative || entry.Rescode != uint16(NOERROR) {
+		t.Fatalf("cached entry = %+v, ok=%v, want a negative entry with Rescode NOERROR", entry, ok)
+	}
+
+	second := r.Resolve(msg)
+	if second.Header.Rescode != NOERROR || len(second.Answer) != 0 {
+		t.Errorf("reply on replay = %+v, want NOERROR with no answers", second)
+	}
+	if up.calls != 1 {
+		t.Errorf("upstream calls = %d, want 1 (second query should be answered from the negative cache)", up.calls)
+	}
+}
+
+func TestResolveBlocklistChecksBeforeCache(t *testing.T) {
+	dir := t.TempDir()
+	ruleFile := filepath.Join(dir, "blocked.txt")
+	if err := os.WriteFile(ruleFile, []byte("blocked.example.com\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	bl, err := blocklist.Load(blocklist.Options{Files: []string{ruleFile}, Policy: blocklist.PolicyNXDOMAIN})
+	if err != nil {
+		t.Fatalf("blocklist.Load: %v", err)
+	}
+	defer bl.Close()
+
+	up := &scriptedUpstream{reply: func(msg *DNSMessage) *DNSMessage {
+		t.Fatal("upstream was queried, want the blocklist hit to short-circuit before it")
+		return nil
+	}}
+	r, c := newTestResolver(t, up, bl, nil)
+
+	q := Question{Name: "blocked.example.com", Type: TypeA, Class: ClassIN}
+	rr, err := toCacheRR(Record{Name: q.Name, Type: TypeA, Class: ClassIN, TTL: 300, Data: ARecord{IP: mustParseIP("9.9.9.9")}})
+	if err != nil {
+		t.Fatalf("toCacheRR: %v", err)
+	}
+	if err := c.Put(cacheKey(q), cache.Entry{RRs: []cache.RR{rr}}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	msg := &DNSMessage{Header: NewDNSHeader(), Question: []Question{q}}
+	reply := r.Resolve(msg)
+
+	if reply.Header.Rescode != NXDOMAIN {
+		t.Errorf("Rescode = %v, want NXDOMAIN from the blocklist policy, not the cached answer", reply.Header.Rescode)
+	}
+	if len(reply.Answer) != 0 {
+		t.Errorf("Answer = %+v, want no answers: the cached record must not leak through a blocklist hit", reply.Answer)
+	}
+}
+
+func TestResolveLocalZoneBypassesCacheAndUpstream(t *testing.T) {
+	lz, err := NewLocalZones([]string{"internal.example.com=10.0.0.1"})
+	if err != nil {
+		t.Fatalf("NewLocalZones: %v", err)
+	}
+	up := &scriptedUpstream{reply: func(msg *DNSMessage) *DNSMessage {
+		t.Fatal("upstream was queried, want the local-zone hit to short-circuit before it")
+		return nil
+	}}
+	r, _ := newTestResolver(t, up, nil, lz)
+
+	q := Question{Name: "internal.example.com", Type: TypeA, Class: ClassIN}
+	msg := &DNSMessage{Header: NewDNSHeader(), Question: []Question{q}}
+	reply := r.Resolve(msg)
+
+	if reply.Header.Rescode != NOERROR || !reply.Header.Authoritative_answer {
+		t.Fatalf("header = %+v, want an authoritative NOERROR reply", reply.Header)
+	}
+	if len(reply.Answer) != 1 {
+		t.Fatalf("Answer = %+v, want one record from the local zone", reply.Answer)
+	}
+	a, ok := reply.Answer[0].Data.(ARecord)
+	if !ok || a.IP.String() != "10.0.0.1" {
+		t.Errorf("Answer[0].Data = %+v, want the local-zone A record for 10.0.0.1", reply.Answer[0].Data)
+	}
+}
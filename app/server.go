@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/ndrpp/codecrafters-dns-server-go/nbns"
+)
+
+// maxUDPSize is the plain-DNS UDP payload size per RFC 1035 section
+// 2.3.4, used when a query carries no EDNS0 OPT record negotiating a
+// larger one.
+const maxUDPSize = 512
+
+// Server runs the UDP and TCP listeners that answer queries through a
+// shared Resolver, and coordinates their graceful shutdown. It
+// optionally also runs an NBNS responder on its own UDP socket, for LAN
+// clients that do name discovery over NetBIOS rather than DNS.
+type Server struct {
+	Resolver *Resolver
+	NBNS     *nbns.Responder
+
+	udpConn  *net.UDPConn
+	tcpLn    *net.TCPListener
+	nbnsConn *net.UDPConn
+	wg       sync.WaitGroup
+}
+
+// NewServer binds both the UDP and TCP sockets at addr (e.g.
+// "127.0.0.1:2053") and returns a Server ready to Serve. If responder is
+// non-nil, NewServer also binds nbnsAddr (conventionally "host:137") and
+// has Serve answer NBNS name-query broadcasts there alongside DNS.
+func NewServer(addr string, resolver *Resolver, nbnsAddr string, responder *nbns.Responder) (*Server, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("server: resolve udp %s: %w", addr, err)
+	}
+	udpConn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, fmt.Errorf("server: listen udp %s: %w", addr, err)
+	}
+
+	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		udpConn.Close()
+		return nil, fmt.Errorf("server: resolve tcp %s: %w", addr, err)
+	}
+	tcpLn, err := net.ListenTCP("tcp", tcpAddr)
+	if err != nil {
+		udpConn.Close()
+		return nil, fmt.Errorf("server: listen tcp %s: %w", addr, err)
+	}
+
+	srv := &Server{Resolver: resolver, NBNS: responder, udpConn: udpConn, tcpLn: tcpLn}
+
+	if responder != nil {
+		nbnsUDPAddr, err := net.ResolveUDPAddr("udp", nbnsAddr)
+		if err != nil {
+			udpConn.Close()
+			tcpLn.Close()
+			return nil, fmt.Errorf("server: resolve nbns udp %s: %w", nbnsAddr, err)
+		}
+		nbnsConn, err := net.ListenUDP("udp", nbnsUDPAddr)
+		if err != nil {
+			udpConn.Close()
+			tcpLn.Close()
+			return nil, fmt.Errorf("server: listen nbns udp %s: %w", nbnsAddr, err)
+		}
+		srv.nbnsConn = nbnsConn
+	}
+
+	return srv, nil
+}
+
+// Serve runs the UDP, TCP, and (if configured) NBNS accept loops until
+// ctx is cancelled, then closes every listener and blocks until every
+// in-flight query has finished being handled.
+func (s *Server) Serve(ctx context.Context) {
+	s.wg.Add(2)
+	go s.serveUDP(ctx)
+	go s.serveTCP(ctx)
+	if s.nbnsConn != nil {
+		s.wg.Add(1)
+		go s.serveNBNS(ctx)
+	}
+
+	<-ctx.Done()
+	s.udpConn.Close()
+	s.tcpLn.Close()
+	if s.nbnsConn != nil {
+		s.nbnsConn.Close()
+	}
+	s.wg.Wait()
+}
+
+func (s *Server) serveUDP(ctx context.Context) {
+	defer s.wg.Done()
+
+	buf := make([]byte, 65535)
+	for {
+		size, source, err := s.udpConn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			fmt.Println("udp: read error:", err)
+			continue
+		}
+
+		query := append([]byte(nil), buf[:size]...)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleUDP(source, query)
+		}()
+	}
+}
+
+// handleUDP answers a single UDP query, rebuilding a truncated response
+// in place of the real answer if the latter wouldn't fit in the client's
+// negotiated UDP payload size, so the client knows to retry over TCP.
+func (s *Server) handleUDP(source *net.UDPAddr, query []byte) {
+	msg, err := ParseMessage(query)
+	if err != nil {
+		fmt.Println("udp: failed to parse query:", err)
+		return
+	}
+
+	reply := s.Resolver.Resolve(msg)
+	response, err := reply.Pack()
+	if err != nil {
+		fmt.Println("udp: failed to pack response:", err)
+		return
+	}
+
+	if len(response) > udpSizeLimit(msg) {
+		response, err = truncate(reply).Pack()
+		if err != nil {
+			fmt.Println("udp: failed to pack truncated response:", err)
+			return
+		}
+	}
+
+	if _, err := s.udpConn.WriteToUDP(response, source); err != nil {
+		fmt.Println("udp: failed to send response:", err)
+	}
+}
+
+// udpSizeLimit returns the payload size query's EDNS0 OPT record (if
+// any) negotiated, falling back to the plain-DNS default of 512 bytes.
+// RFC 6891 allows advertising sizes up to 65535, but doesn't set a floor,
+// so a pathologically small value is clamped back up to the default.
+func udpSizeLimit(query *DNSMessage) int {
+	if query.OPT == nil || query.OPT.UDPSize < maxUDPSize {
+		return maxUDPSize
+	}
+	return int(query.OPT.UDPSize)
+}
+
+// truncate strips reply down to header and question, setting the TC bit
+// so the client retries over TCP per RFC 1035 section 4.1.1.
+func truncate(reply *DNSMessage) *DNSMessage {
+	header := reply.Header
+	header.Truncated_message = true
+	return &DNSMessage{Header: header, Question: reply.Question}
+}
+
+func (s *Server) serveTCP(ctx context.Context) {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.tcpLn.AcceptTCP()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			fmt.Println("tcp: accept error:", err)
+			continue
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleTCP(conn)
+		}()
+	}
+}
+
+// handleTCP serves every length-prefixed query on conn until the client
+// closes it or a framing error occurs. Unlike UDP, a TCP reply is never
+// truncated: RFC 1035 section 4.2.2 message length is 65535 bytes, far
+// beyond any reply this server builds.
+func (s *Server) handleTCP(conn *net.TCPConn) {
+	defer conn.Close()
+
+	for {
+		query, err := readTCPMessage(conn)
+		if err != nil {
+			return
+		}
+
+		msg, err := ParseMessage(query)
+		if err != nil {
+			fmt.Println("tcp: failed to parse query:", err)
+			return
+		}
+
+		reply := s.Resolver.Resolve(msg)
+		response, err := reply.Pack()
+		if err != nil {
+			fmt.Println("tcp: failed to pack response:", err)
+			return
+		}
+
+		if err := writeTCPMessage(conn, response); err != nil {
+			fmt.Println("tcp: failed to send response:", err)
+			return
+		}
+	}
+}
+
+// serveNBNS answers NBNS name-query broadcasts on s.nbnsConn until ctx
+// is cancelled. Unlike DNS, most NBNS traffic isn't addressed to this
+// responder at all (every host on the segment sees every broadcast), so
+// Handle reporting no match is the common case, not an error.
+func (s *Server) serveNBNS(ctx context.Context) {
+	defer s.wg.Done()
+
+	buf := make([]byte, 576)
+	for {
+		size, source, err := s.nbnsConn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			fmt.Println("nbns: read error:", err)
+			continue
+		}
+
+		reply, ok, err := s.NBNS.Handle(buf[:size])
+		if err != nil {
+			fmt.Println("nbns: failed to handle packet:", err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		if _, err := s.nbnsConn.WriteToUDP(reply, source); err != nil {
+			fmt.Println("nbns: failed to send response:", err)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// queryTimeout bounds how long a single upstream exchange (UDP or its TCP
+// fallback) is allowed to take before the resolver gives up and tries the
+// next upstream in the pool.
+const queryTimeout = 2 * time.Second
+
+// Upstream sends a query to some other DNS server and returns its reply.
+type Upstream interface {
+	// Exchange sends msg and returns the parsed response.
+	Exchange(msg *DNSMessage) (*DNSMessage, error)
+	// Address reports the upstream's dial address, for logging and
+	// round-robin bookkeeping.
+	Address() string
+}
+
+// udpUpstream talks to a single nameserver over UDP, falling back to TCP
+// when the UDP reply has the truncated bit set.
+type udpUpstream struct {
+	addr string
+}
+
+// AddressToUpstream builds an Upstream from a bare "host:port" address,
+// defaulting to port 53 when none is given.
+func AddressToUpstream(addr string) (Upstream, error) {
+	if addr == "" {
+		return nil, fmt.Errorf("upstream: empty address")
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "53")
+	}
+	return &udpUpstream{addr: addr}, nil
+}
+
+func (u *udpUpstream) Address() string { return u.addr }
+
+func (u *udpUpstream) Exchange(msg *DNSMessage) (*DNSMessage, error) {
+	query, err := msg.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("upstream %s: pack query: %w", u.addr, err)
+	}
+
+	reply, err := u.exchangeUDP(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if reply.Header.Truncated_message {
+		reply, err = u.exchangeTCP(query)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return reply, nil
+}
+
+func (u *udpUpstream) exchangeUDP(query []byte) (*DNSMessage, error) {
+	conn, err := net.DialTimeout("udp", u.addr, queryTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("upstream %s: dial udp: %w", u.addr, err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(queryTimeout))
+	if _, err := conn.Write(query); err != nil {
+		return nil, fmt.Errorf("upstream %s: write udp: %w", u.addr, err)
+	}
+
+	buf := make([]byte, 65535)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, fmt.Errorf("upstream %s: read udp: %w", u.addr, err)
+	}
+
+	reply, err := ParseMessage(buf[:n])
+	if err != nil {
+		return nil, fmt.Errorf("upstream %s: parse udp reply: %w", u.addr, err)
+	}
+	return reply, nil
+}
+
+func (u *udpUpstream) exchangeTCP(query []byte) (*DNSMessage, error) {
+	conn, err := net.DialTimeout("tcp", u.addr, queryTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("upstream %s: dial tcp: %w", u.addr, err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(queryTimeout))
+	if err := writeTCPMessage(conn, query); err != nil {
+		return nil, fmt.Errorf("upstream %s: write tcp: %w", u.addr, err)
+	}
+
+	data, err := readTCPMessage(conn)
+	if err != nil {
+		return nil, fmt.Errorf("upstream %s: read tcp: %w", u.addr, err)
+	}
+
+	reply, err := ParseMessage(data)
+	if err != nil {
+		return nil, fmt.Errorf("upstream %s: parse tcp reply: %w", u.addr, err)
+	}
+	return reply, nil
+}
+
+// selectionStrategy picks the order in which an UpstreamPool tries its
+// members.
+type selectionStrategy int
+
+const (
+	// RoundRobin cycles through upstreams on successive calls to Pick.
+	RoundRobin selectionStrategy = iota
+	// LowestLatency always tries the upstream with the best rolling
+	// average response time first.
+	LowestLatency
+)
+
+// UpstreamPool fans a query out to one of several configured upstreams,
+// selected either round-robin or by observed latency, and retries the
+// next one on failure or SERVFAIL. A pool is shared across every
+// concurrent query the server handles, so next and latency are guarded
+// by mu rather than left to the caller to serialize.
+type UpstreamPool struct {
+	strategy  selectionStrategy
+	upstreams []Upstream
+
+	mu      sync.Mutex
+	latency map[string]time.Duration
+	next    int
+}
+
+// NewUpstreamPool builds a pool over upstreams using the given selection
+// strategy. At least one upstream must be provided.
+func NewUpstreamPool(strategy selectionStrategy, upstreams []Upstream) (*UpstreamPool, error) {
+	if len(upstreams) == 0 {
+		return nil, fmt.Errorf("upstream: pool needs at least one upstream")
+	}
+	return &UpstreamPool{
+		strategy:  strategy,
+		upstreams: upstreams,
+		latency:   make(map[string]time.Duration),
+	}, nil
+}
+
+// order returns the pool's upstreams in the order they should be tried.
+func (p *UpstreamPool) order() []Upstream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.strategy == LowestLatency {
+		ordered := append([]Upstream(nil), p.upstreams...)
+		sortByLatency(ordered, p.latency)
+		return ordered
+	}
+
+	start := p.next
+	p.next = (p.next + 1) % len(p.upstreams)
+	ordered := make([]Upstream, len(p.upstreams))
+	for i := range p.upstreams {
+		ordered[i] = p.upstreams[(start+i)%len(p.upstreams)]
+	}
+	return ordered
+}
+
+// recordLatency stores how long up took to answer the most recent query,
+// for LowestLatency ordering.
+func (p *UpstreamPool) recordLatency(up Upstream, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.latency[up.Address()] = d
+}
+
+func sortByLatency(upstreams []Upstream, latency map[string]time.Duration) {
+	for i := 1; i < len(upstreams); i++ {
+		for j := i; j > 0 && latency[upstreams[j].Address()] < latency[upstreams[j-1].Address()]; j-- {
+			upstreams[j], upstreams[j-1] = upstreams[j-1], upstreams[j]
+		}
+	}
+}
+
+// Exchange tries each upstream in turn, moving on from a transport error
+// or a SERVFAIL response, and returns the first usable reply.
+func (p *UpstreamPool) Exchange(msg *DNSMessage) (*DNSMessage, error) {
+	var lastErr error
+
+	for _, up := range p.order() {
+		start := time.Now()
+		reply, err := up.Exchange(msg)
+		p.recordLatency(up, time.Since(start))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if reply.Header.Rescode == SERVFAIL {
+			lastErr = fmt.Errorf("upstream %s: SERVFAIL", up.Address())
+			continue
+		}
+		return reply, nil
+	}
+
+	return nil, fmt.Errorf("upstream: all upstreams failed: %w", lastErr)
+}
+
+// parseUpstreamList builds an UpstreamPool from repeated --upstream flag
+// values (comma- or flag-repetition-separated "host:port" addresses).
+func parseUpstreamList(addrs []string, strategy selectionStrategy) (*UpstreamPool, error) {
+	var upstreams []Upstream
+	for _, a := range addrs {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		u, err := AddressToUpstream(a)
+		if err != nil {
+			return nil, err
+		}
+		upstreams = append(upstreams, u)
+	}
+	return NewUpstreamPool(strategy, upstreams)
+}
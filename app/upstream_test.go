@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// fakeUpstream is an in-process Upstream used to exercise UpstreamPool
+// without any real network traffic.
+type fakeUpstream struct {
+	addr    string
+	rescode ResultCode
+	err     error
+	calls   int
+}
+
+func (f *fakeUpstream) Address() string { return f.addr }
+
+func (f *fakeUpstream) Exchange(msg *DNSMessage) (*DNSMessage, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	header := NewDNSHeader()
+	header.Rescode = f.rescode
+	return &DNSMessage{Header: header}, nil
+}
+
+func TestUpstreamPoolRoundRobinCyclesStart(t *testing.T) {
+	a := &fakeUpstream{addr: "a"}
+	b := &fakeUpstream{addr: "b"}
+	c := &fakeUpstream{addr: "c"}
+	pool, err := NewUpstreamPool(RoundRobin, []Upstream{a, b, c})
+	if err != nil {
+		t.Fatalf("NewUpstreamPool: %v", err)
+	}
+
+	var gotFirst []string
+	for i := 0; i < 3; i++ {
+		gotFirst = append(gotFirst, pool.order()[0].Address())
+	}
+
+	want := []string{"a", "b", "c"}
+	for i, addr := range want {
+		if gotFirst[i] != addr {
+			t.Errorf("order()[0] on call %d = %q, want %q", i, gotFirst[i], addr)
+		}
+	}
+}
+
+func TestUpstreamPoolExchangeSkipsServfail(t *testing.T) {
+	bad := &fakeUpstream{addr: "bad", rescode: SERVFAIL}
+	good := &fakeUpstream{addr: "good", rescode: NOERROR}
+	pool, err := NewUpstreamPool(RoundRobin, []Upstream{bad, good})
+	if err != nil {
+		t.Fatalf("NewUpstreamPool: %v", err)
+	}
+
+	reply, err := pool.Exchange(&DNSMessage{Header: NewDNSHeader()})
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if reply.Header.Rescode != NOERROR {
+		t.Errorf("Rescode = %v, want NOERROR", reply.Header.Rescode)
+	}
+	if bad.calls != 1 || good.calls != 1 {
+		t.Errorf("calls = bad:%d good:%d, want 1 each", bad.calls, good.calls)
+	}
+}
+
+func TestUpstreamPoolExchangeSkipsTransportError(t *testing.T) {
+	broken := &fakeUpstream{addr: "broken", err: fmt.Errorf("dial: refused")}
+	good := &fakeUpstream{addr: "good", rescode: NOERROR}
+	pool, err := NewUpstreamPool(RoundRobin, []Upstream{broken, good})
+	if err != nil {
+		t.Fatalf("NewUpstreamPool: %v", err)
+	}
+
+	reply, err := pool.Exchange(&DNSMessage{Header: NewDNSHeader()})
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if reply.Header.Rescode != NOERROR {
+		t.Errorf("Rescode = %v, want NOERROR", reply.Header.Rescode)
+	}
+}
+
+func TestUpstreamPoolExchangeFailsWhenAllUpstreamsFail(t *testing.T) {
+	a := &fakeUpstream{addr: "a", rescode: SERVFAIL}
+	b := &fakeUpstream{addr: "b", err: fmt.Errorf("timeout")}
+	pool, err := NewUpstreamPool(RoundRobin, []Upstream{a, b})
+	if err != nil {
+		t.Fatalf("NewUpstreamPool: %v", err)
+	}
+
+	if _, err := pool.Exchange(&DNSMessage{Header: NewDNSHeader()}); err == nil {
+		t.Fatal("Exchange succeeded, want an error when every upstream fails")
+	}
+}
+
+// TestUpstreamPoolConcurrentExchange exercises the pool the way the
+// server actually drives it: many goroutines calling Exchange at once.
+// Run with -race to catch regressions in the locking around next/latency.
+func TestUpstreamPoolConcurrentExchange(t *testing.T) {
+	a := &fakeUpstream{addr: "a", rescode: NOERROR}
+	b := &fakeUpstream{addr: "b", rescode: NOERROR}
+	pool, err := NewUpstreamPool(LowestLatency, []Upstream{a, b})
+	if err != nil {
+		t.Fatalf("NewUpstreamPool: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := pool.Exchange(&DNSMessage{Header: NewDNSHeader()}); err != nil {
+				t.Errorf("Exchange: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// maxPointerOffset is the largest offset a 14-bit compression pointer can
+// address; names first written at or beyond this offset can't be
+// referenced by later pointers.
+const maxPointerOffset = 0x3FFF
+
+// Writer serializes a DNSMessage into wire format, deduplicating domain
+// names with RFC 1035 compression pointers the same way a packet parsed
+// off the wire would use them.
+type Writer struct {
+	buf   bytes.Buffer
+	names map[string]uint16
+}
+
+// NewWriter returns a Writer ready to serialize a message starting at
+// offset 0 (the usual case for a standalone DNS packet).
+func NewWriter() *Writer {
+	return &Writer{names: make(map[string]uint16)}
+}
+
+func (w *Writer) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+func (w *Writer) Len() int {
+	return w.buf.Len()
+}
+
+func (w *Writer) WriteU8(v uint8) {
+	w.buf.WriteByte(v)
+}
+
+func (w *Writer) WriteU16(v uint16) {
+	binary.Write(&w.buf, binary.BigEndian, v)
+}
+
+func (w *Writer) WriteU32(v uint32) {
+	binary.Write(&w.buf, binary.BigEndian, v)
+}
+
+func (w *Writer) WriteBytes(b []byte) {
+	w.buf.Write(b)
+}
+
+// WriteName writes name in label form, reusing the longest previously
+// written suffix via a compression pointer when one is in range. Every
+// suffix of name that is written out fresh is recorded so later names can
+// point into it.
+func (w *Writer) WriteName(name string) error {
+	labels := splitLabels(name)
+
+	for i := 0; i < len(labels); i++ {
+		suffix := strings.Join(labels[i:], ".")
+		if offset, ok := w.names[suffix]; ok {
+			w.WriteU16(0xC000 | offset)
+			return nil
+		}
+
+		if w.buf.Len() <= maxPointerOffset {
+			w.names[suffix] = uint16(w.buf.Len())
+		}
+
+		label := labels[i]
+		if len(label) > 63 {
+			return fmt.Errorf("writer: label %q exceeds 63 bytes", label)
+		}
+		w.WriteU8(uint8(len(label)))
+		w.WriteBytes([]byte(label))
+	}
+
+	w.WriteU8(0)
+	return nil
+}
+
+// splitLabels splits a dotted name into its labels, dropping an empty
+// trailing label produced by a root ("." or "example.com.") name.
+func splitLabels(name string) []string {
+	if name == "" || name == "." {
+		return nil
+	}
+	labels := strings.Split(name, ".")
+	if labels[len(labels)-1] == "" {
+		labels = labels[:len(labels)-1]
+	}
+	return labels
+}